@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/bensohh/go-admin/models"
+)
+
+// Every API key starts with this prefix, which tells them apart from the JWTs
+const APIKeyPrefix = "gak_"
+
+// Number of characters of a key kept in APIKey.Prefix
+const apiKeyPrefixLength = 12
+
+// Generates a new API key, returns the key given to the teacher along with its hash and prefix stored in the database
+func NewAPIKey() (key string, hash string, prefix string, err error) {
+	key, hash, err = newToken(APIKeyPrefix)
+	if err != nil {
+		return "", "", "", err
+	}
+	return key, hash, key[:apiKeyPrefixLength], nil
+}
+
+// SHA-256 of the key, the keys are random so they do not need a slow hash
+func HashAPIKey(key string) string {
+	return hashToken(key)
+}
+
+// Generates a random token with the given prefix, returns it along with its hash
+func newToken(prefix string) (token string, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = prefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashToken(token), nil
+}
+
+// SHA-256 of a random token, stored instead of the token itself
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Creates an API key for the teacher, returns the key itself which cannot be retrieved afterwards.
+// Returns models.ErrNotFound if the teacher does not exist.
+func IssueAPIKey(keys models.APIKeyStore, teacherEmail string, name string) (string, *models.APIKey, error) {
+	key, hash, prefix, err := NewAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	apiKey := &models.APIKey{TeacherEmail: teacherEmail, Name: name, Prefix: prefix, Hash: hash}
+	if err := keys.CreateAPIKey(apiKey); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
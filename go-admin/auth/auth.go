@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+)
+
+// Returned (wrapped) when the credentials of a request are missing or invalid
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Ways a principal can authenticate
+const (
+	MethodJWT     = "jwt"
+	MethodAPIKey  = "api_key"
+	MethodSession = "session"
+)
+
+// Authenticated caller of the API
+type Principal struct {
+	// Email of the teacher the caller acts as
+	TeacherEmail string `json:"teacher"`
+	// Role of the teacher's account, see models.RoleAdmin
+	Role string `json:"role"`
+	// jwt, api_key or session
+	Method string `json:"method"`
+	// ID of the API key, 0 unless authenticated with an API key
+	APIKeyID uint `json:"api_key_id,omitempty"`
+	// ID of the session, empty unless authenticated with a session access token
+	SessionID string `json:"session_id,omitempty"`
+	// Whether the caller passed a two-factor authentication: during the session,
+	// or before the JWT was issued (`amr` claim with `mfa` or `otp`)
+	TwoFactor bool `json:"two_factor"`
+}
+
+// Authenticates the requests with bearer JWTs (HMAC signed, verified locally), API keys or session access tokens,
+// the last two stored hashed in the database
+type Authenticator struct {
+	// HMAC secret of the JWTs, the JWTs are rejected if empty
+	Secret []byte
+	// Expected iss and aud claims of the JWTs, not checked if empty
+	Issuer   string
+	Audience string
+	Accounts Accounts
+}
+
+// Teachers the callers authenticate as, along with their API keys, sessions, password resets and second factors
+type Accounts interface {
+	models.TeacherStore
+	models.APIKeyStore
+	models.SessionStore
+	models.PasswordResetStore
+	models.TwoFactorStore
+}
+
+// Authenticator accepting the API keys of the store and, if secret is not empty, the JWTs signed with it
+func NewAuthenticator(accounts Accounts, secret []byte) *Authenticator {
+	return &Authenticator{Secret: secret, Accounts: accounts}
+}
+
+// Authenticates the request from its `Authorization: Bearer <token>` header, where the token is a JWT, an API key
+// or a session access token, or from its `X-API-Key` header
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, fmt.Errorf("%w: missing credentials", ErrUnauthenticated)
+	}
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: expected a bearer token", ErrUnauthenticated)
+	}
+	if isAPIKey(token) {
+		return a.authenticateAPIKey(token)
+	}
+	if strings.HasPrefix(token, AccessTokenPrefix) {
+		return a.authenticateSession(token)
+	}
+	return a.authenticateJWT(token)
+}
+
+func (a *Authenticator) authenticateJWT(token string) (*Principal, error) {
+	if len(a.Secret) == 0 {
+		return nil, fmt.Errorf("%w: tokens are not accepted", ErrUnauthenticated)
+	}
+	claims, err := VerifyJWT(a.Secret, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if a.Issuer != "" && claims.Issuer != a.Issuer {
+		return nil, fmt.Errorf("%w: unexpected token issuer", ErrUnauthenticated)
+	}
+	if a.Audience != "" && !claims.Audience.contains(a.Audience) {
+		return nil, fmt.Errorf("%w: unexpected token audience", ErrUnauthenticated)
+	}
+	return a.principal(&Principal{TeacherEmail: strings.ToLower(claims.Subject), Method: MethodJWT, TwoFactor: claims.multiFactor()})
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (*Principal, error) {
+	apiKey, err := a.Accounts.GetAPIKeyByHash(HashAPIKey(key))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: revoked API key", ErrUnauthenticated)
+	}
+	return a.principal(&Principal{TeacherEmail: apiKey.TeacherEmail, Method: MethodAPIKey, APIKeyID: apiKey.ID})
+}
+
+func (a *Authenticator) authenticateSession(token string) (*Principal, error) {
+	session, err := a.Accounts.GetSessionByAccessHash(hashToken(token))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid session token", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: revoked session", ErrUnauthenticated)
+	}
+	if !time.Now().Before(session.AccessExpiresAt) {
+		return nil, fmt.Errorf("%w: session token expired", ErrUnauthenticated)
+	}
+	return a.principal(&Principal{
+		TeacherEmail: session.TeacherEmail,
+		Method:       MethodSession,
+		SessionID:    session.ID,
+		TwoFactor:    session.TwoFactorAt != nil,
+	})
+}
+
+// Completes the principal with the role of its account, the teacher may have been deleted since the token was issued
+func (a *Authenticator) principal(principal *Principal) (*Principal, error) {
+	teacher, err := a.Accounts.GetTeacher(principal.TeacherEmail)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown account", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	principal.Role = teacher.Role
+	return principal, nil
+}
+
+// Responds with 401 to the requests which cannot be authenticated, the principal of the others is added to their context
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+
+		if errors.Is(err, ErrUnauthenticated) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			utils.RespondWithError(w, http.StatusUnauthorized, utils.CodeUnauthenticated, "Unauthorized, "+strings.TrimPrefix(err.Error(), ErrUnauthenticated.Error()+": "))
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error authenticating request")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// Returns the principal added by the middleware, nil if the request was not authenticated
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(contextKey{}).(*Principal)
+	return principal
+}
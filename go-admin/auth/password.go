@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Length limits of the passwords, bcrypt ignores what comes after 72 bytes
+const (
+	minPasswordLength = 10
+	maxPasswordLength = 72
+)
+
+// Returned when a password is too short or too long
+var ErrInvalidPassword = fmt.Errorf("password must be %d to %d bytes long", minPasswordLength, maxPasswordLength)
+
+// Returned when the email or the password does not match a teacher who has set a password
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// Compared against when the teacher has no password, so that unknown emails take as long as the known ones
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Hashes the password with bcrypt, returns ErrInvalidPassword if its length is out of the limits
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Whether the password matches the bcrypt hash, always false if the hash is empty
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
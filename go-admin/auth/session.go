@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+)
+
+// Prefixes of the tokens issued by the login, which tell them apart from the API keys and the JWTs
+const (
+	AccessTokenPrefix  = "gas_"
+	RefreshTokenPrefix = "gar_"
+	ResetTokenPrefix   = "gpr_"
+)
+
+// Lifetimes of the tokens
+const (
+	// The access token is refreshed with the refresh token once expired
+	AccessTokenTTL = 15 * time.Minute
+	// The session cannot be refreshed anymore after this time, the teacher logs in again
+	SessionTTL = 30 * 24 * time.Hour
+	// Password reset tokens are single use
+	ResetTokenTTL = 24 * time.Hour
+)
+
+// Returned (wrapped) when a password reset token is unknown, used or expired
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// Tokens of a session, only returned when the session is created or refreshed
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      *models.Session
+	// Whether the teacher has to pass the two-factor authentication during the session (see VerifyTwoFactor)
+	TwoFactorRequired bool
+}
+
+// Stores the session along with the hashes of its new tokens
+func newSessionTokens(session *models.Session, now time.Time) (*SessionTokens, error) {
+	access, accessHash, err := newToken(AccessTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	refresh, refreshHash, err := newToken(RefreshTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	session.AccessHash = accessHash
+	session.RefreshHash = refreshHash
+	session.AccessExpiresAt = now.Add(AccessTokenTTL)
+	return &SessionTokens{AccessToken: access, RefreshToken: refresh, Session: session}, nil
+}
+
+// Checks the teacher's password and starts a session, returns ErrInvalidCredentials if they do not match
+func Login(accounts Accounts, email string, password string) (*SessionTokens, error) {
+	teacher, err := accounts.GetTeacher(email)
+	if errors.Is(err, models.ErrNotFound) {
+		CheckPassword("", password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !CheckPassword(teacher.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	tokens, err := newSessionTokens(&models.Session{ID: utils.NewID(), TeacherEmail: teacher.Email, ExpiresAt: now.Add(SessionTTL)}, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := accounts.CreateSession(tokens.Session); err != nil {
+		return nil, err
+	}
+	tokens.TwoFactorRequired = teacher.TOTPEnabled
+	return tokens, nil
+}
+
+// Replaces both tokens of the session of the refresh token, which can only be used once.
+// Returns ErrUnauthenticated (wrapped) if the refresh token is invalid, revoked or expired.
+func Refresh(accounts Accounts, refreshToken string) (*SessionTokens, error) {
+	previousHash := hashToken(refreshToken)
+	session, err := accounts.GetSessionByRefreshHash(previousHash)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid refresh token", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if session.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: revoked session", ErrUnauthenticated)
+	}
+	if !now.Before(session.ExpiresAt) {
+		return nil, fmt.Errorf("%w: session expired", ErrUnauthenticated)
+	}
+
+	tokens, err := newSessionTokens(session, now)
+	if err != nil {
+		return nil, err
+	}
+	// Fails if the refresh token was used concurrently or the session revoked meanwhile
+	err = accounts.RotateSession(tokens.Session, previousHash)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid refresh token", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Creates a password reset token for the teacher, returns the token itself which cannot be retrieved afterwards.
+// Returns models.ErrNotFound if the teacher does not exist.
+func IssuePasswordReset(accounts Accounts, teacherEmail string) (string, *models.PasswordReset, error) {
+	token, hash, err := newToken(ResetTokenPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	reset := &models.PasswordReset{Hash: hash, TeacherEmail: teacherEmail, ExpiresAt: time.Now().Add(ResetTokenTTL)}
+	if err := accounts.CreatePasswordReset(reset); err != nil {
+		return "", nil, err
+	}
+	return token, reset, nil
+}
+
+// Sets the password of the teacher of the reset token and revokes their sessions.
+// Returns ErrInvalidPassword or ErrInvalidResetToken (wrapped) if they are invalid.
+func ResetPassword(accounts Accounts, token string, password string) (*models.PasswordReset, error) {
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	reset, err := accounts.ResetPassword(hashToken(token), passwordHash, time.Now())
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reset, nil
+}
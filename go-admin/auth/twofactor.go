@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+)
+
+// Number of recovery codes given when the TOTP is enabled
+const recoveryCodeCount = 10
+
+const (
+	// Number of codes rejected in a row after which the two-factor authentication of the teacher is locked
+	MaxTwoFactorFailures = 5
+	// How long the two-factor authentication stays locked
+	TwoFactorLockout = 15 * time.Minute
+)
+
+var (
+	// Returned when a TOTP or recovery code does not match, or was already used
+	ErrInvalidCode = errors.New("invalid two-factor code")
+	// Returned when enrolling a teacher whose TOTP is already enabled
+	ErrTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+	// Returned when confirming or verifying a teacher who did not enroll
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication not enabled")
+	// Returned while the two-factor authentication of the teacher is locked, see MaxTwoFactorFailures
+	ErrTwoFactorLocked = errors.New("two-factor authentication locked after too many rejected codes")
+)
+
+// Secret of a TOTP enrollment, shown once to the teacher who adds it to their authenticator app
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// Starts the TOTP enrollment of the teacher, which is only enabled once confirmed with a code (see ConfirmTOTP).
+// Enrolling again before confirming replaces the secret.
+func EnrollTOTP(accounts Accounts, teacherEmail string) (*TOTPEnrollment, error) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = accounts.SetTOTPSecret(teacherEmail, secret)
+	if errors.Is(err, models.ErrDuplicate) {
+		return nil, ErrTwoFactorEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: TOTPURI(secret, teacherEmail)}, nil
+}
+
+// Enables the TOTP of the teacher if the code matches the secret they enrolled with,
+// returns their recovery codes which cannot be retrieved afterwards
+func ConfirmTOTP(accounts Accounts, teacherEmail string, code string) ([]string, error) {
+	teacher, err := accounts.GetTeacher(teacherEmail)
+	if err != nil {
+		return nil, err
+	}
+	if teacher.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if teacher.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	var step int64
+	err = checkTwoFactor(accounts, teacher, func() error {
+		var ok bool
+		if step, ok = checkTOTP(teacher.TOTPSecret, strings.TrimSpace(code), time.Now()); !ok {
+			return ErrInvalidCode
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := accounts.EnableTOTP(teacher.Email, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Checks a TOTP code (or a recovery code) of the teacher, each code is only accepted once
+func VerifyTwoFactor(accounts Accounts, teacherEmail string, code string) error {
+	teacher, err := accounts.GetTeacher(teacherEmail)
+	if err != nil {
+		return err
+	}
+	if !teacher.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	return checkTwoFactor(accounts, teacher, func() error {
+		code = strings.TrimSpace(code)
+		if len(code) == totpDigits {
+			step, ok := checkTOTP(teacher.TOTPSecret, code, time.Now())
+			if !ok {
+				return ErrInvalidCode
+			}
+			err = accounts.UseTOTPStep(teacher.Email, step)
+		} else {
+			err = accounts.UseRecoveryCode(teacher.Email, hashToken(normalizeRecoveryCode(code)), time.Now())
+		}
+		if errors.Is(err, models.ErrDuplicate) || errors.Is(err, models.ErrNotFound) {
+			return ErrInvalidCode
+		}
+		return err
+	})
+}
+
+// Checks a code of the teacher with check unless their two-factor authentication is locked. The codes rejected
+// in a row are counted, the MaxTwoFactorFailures-th locks it for TwoFactorLockout. An accepted code resets the count.
+func checkTwoFactor(accounts Accounts, teacher *models.Teacher, check func() error) error {
+	now := time.Now()
+	if teacher.TwoFactorLockedUntil != nil && now.Before(*teacher.TwoFactorLockedUntil) {
+		return ErrTwoFactorLocked
+	}
+
+	err := check()
+	if errors.Is(err, ErrInvalidCode) {
+		failures, recordErr := accounts.RecordTwoFactorFailure(teacher.Email)
+		if recordErr != nil {
+			return recordErr
+		}
+		if failures < MaxTwoFactorFailures {
+			return err
+		}
+		lockedUntil := now.Add(TwoFactorLockout)
+		if err := accounts.ResetTwoFactorFailures(teacher.Email, &lockedUntil); err != nil {
+			return err
+		}
+		return ErrTwoFactorLocked
+	}
+	if err == nil && (teacher.TwoFactorFailures > 0 || teacher.TwoFactorLockedUntil != nil) {
+		return accounts.ResetTwoFactorFailures(teacher.Email, nil)
+	}
+	return err
+}
+
+// Generates the recovery codes, formatted as xxxx-xxxx-xxxx-xxxx, along with their hashes
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		random := make([]byte, 10)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(random))
+		codes = append(codes, code[0:4]+"-"+code[4:8]+"-"+code[8:12]+"-"+code[12:16])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// Recovery codes are accepted whatever their case and dashes
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
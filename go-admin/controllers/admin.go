@@ -2,35 +2,108 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bensohh/go-admin/auth"
 	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/notifications"
 	"github.com/bensohh/go-admin/utils"
 )
 
 type CommonStudentsResponse struct {
 	Students []string `json:"students"`
+	// Listed teachers (or classes) each student of the page matched, only set with the `matches=true` query param
+	Matches    map[string][]string `json:"matches,omitempty"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// How the students are matched against the teachers (or classes) listed in a common students query
+type matchMode struct {
+	// all, any, min or only
+	name string
+	// Minimum number of listed teachers matched, for the min mode
+	min int
+}
+
+// Parses the `mode` query param (all by default), N of min=N must be between 1 and the number of listed teachers
+func parseMatchMode(value string, listed int) (matchMode, bool) {
+	switch value {
+	case "", "all":
+		return matchMode{name: "all"}, true
+	case "any", "only":
+		return matchMode{name: value}, true
+	}
+
+	n, found := strings.CutPrefix(value, "min=")
+	if !found {
+		return matchMode{}, false
+	}
+	atLeast, err := strconv.Atoi(n)
+	if err != nil || atLeast < 1 || atLeast > listed {
+		return matchMode{}, false
+	}
+	return matchMode{name: "min", min: atLeast}, true
+}
+
+// Whether a student who matched some of the listed teachers (and has total teachers) is part of the result
+func (m matchMode) includes(matched int, listed int, total int) bool {
+	switch m.name {
+	case "any":
+		return matched > 0
+	case "min":
+		return matched >= m.min
+	case "only":
+		return matched == listed && total == listed
+	default:
+		return matched == listed
+	}
 }
 
 type RegisterStudentsRequest struct {
-	Teacher  string   `json:"teacher"`
-	Students []string `json:"students"`
+	// Optional, must be the authenticated teacher if set unless they are an admin
+	Teacher string `json:"teacher,omitempty" validate:"omitempty,email"`
+	// Code of a class taught by the teacher, the teacher's own class is used if not set
+	Class string `json:"class,omitempty" validate:"max=255"`
+	// Name of the term, the current term is used if not set
+	Term     string   `json:"term,omitempty" validate:"max=64"`
+	Students []string `json:"students" validate:"required,min=1,max=1000,dive,email"`
 }
 
+// Same shape as RegisterStudentsRequest
+type DeregisterStudentsRequest = RegisterStudentsRequest
+
 type SuspendStudentRequest struct {
-	Student string `json:"student"`
+	Student  string     `json:"student" validate:"required,email"`
+	Reason   string     `json:"reason,omitempty" validate:"max=1000"`
+	StartsAt *time.Time `json:"starts_at,omitempty"` // Defaults to now
+	EndsAt   *time.Time `json:"ends_at,omitempty"`   // Suspended until un-suspended if not set
+}
+
+type UnSuspendStudentRequest struct {
+	Student string `json:"student" validate:"required,email"`
+	Reason  string `json:"reason,omitempty" validate:"max=1000"`
 }
 
 type GetStudentsWithNotificationRequest struct {
-	Teacher      string `json:"teacher"`
-	Notification string `json:"notification"`
+	// Optional, must be the authenticated teacher if set unless they are an admin
+	Teacher      string `json:"teacher,omitempty" validate:"omitempty,email"`
+	Notification string `json:"notification" validate:"required,max=10000"`
+	// Name of the term whose registrations are used, the current term is used if not set
+	Term string `json:"term,omitempty" validate:"max=64"`
 }
 
 type GetStudentsWithNotificationResponse struct {
-	Recipients []string `json:"recipients"`
+	Recipients        []string `json:"recipients"`
+	UnknownMentions   []string `json:"unknown_mentions"`
+	SuspendedMentions []string `json:"suspended_mentions"`
+	UnknownGroups     []string `json:"unknown_groups"`
+	// Only set with the `explain=true` query param
+	Explanations []notifications.Candidate `json:"explanations,omitempty"`
 }
 
 // Checks if the server is running.
@@ -40,246 +113,409 @@ func TestServer(w http.ResponseWriter, r *http.Request) {
 }
 
 // Checks if a student exists in the db based on their email
-func CheckStudentExists(email string) bool {
-	var student models.Student
-	err := models.DB.Where("email = ?", email).First(&student)
-	if err.Error != nil {
-		log.Println(err.Error)
+func (c *Controller) CheckStudentExists(email string) bool {
+	_, err := c.Store.GetStudent(email)
+	if err != nil {
+		log.Println(err)
 		return false
 	}
 	return true
 }
 
-// Registers one/more students to a specified teacher
-func RegisterStudents(w http.ResponseWriter, r *http.Request) {
+// Retrieves the teacher the caller acts as, which is the authenticated teacher. The teacher sent in the body is optional,
+// if set it must be the authenticated teacher, except for the admins who act as the teacher sent.
+// Responds with an error if the teacher cannot be found.
+func (c *Controller) actingTeacher(w http.ResponseWriter, r *http.Request, bodyTeacher string) (*models.Teacher, bool) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, utils.CodeUnauthenticated, "Unauthorized")
+		return nil, false
+	}
+	email := principal.TeacherEmail
+	if bodyTeacher != "" && !strings.EqualFold(strings.TrimSpace(bodyTeacher), email) {
+		// Admins may act as any teacher
+		if principal.Role != models.RoleAdmin {
+			utils.RespondWithError(w, http.StatusForbidden, codeNotOwnTeacher, "Cannot act as another teacher")
+			return nil, false
+		}
+		email = strings.ToLower(strings.TrimSpace(bodyTeacher))
+	}
+
+	teacher, err := c.Store.GetTeacher(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusBadRequest, codeTeacherNotFound, "Invalid teacher's email")
+		return nil, false
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving teacher")
+		return nil, false
+	}
+	return teacher, true
+}
+
+// Retrieves a class taught by the teacher, responds with an error if there is no such class
+func (c *Controller) teacherClass(w http.ResponseWriter, teacherEmail string, code string) (*models.Class, bool) {
+	class, err := c.Store.GetClass(code)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusBadRequest, codeClassNotFound, "Invalid class")
+		return nil, false
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving class")
+		return nil, false
+	}
+	for _, teacher := range class.Teachers {
+		if teacher == teacherEmail {
+			return class, true
+		}
+	}
+	utils.RespondWithError(w, http.StatusBadRequest, codeNotOwnClass, "Teacher does not teach this class")
+	return nil, false
+}
+
+// Registers one/more students to a specified teacher, or to one of the teacher's classes
+func (c *Controller) RegisterStudents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Retrieve the body parameters
 	var bodyParams RegisterStudentsRequest
-	err := json.NewDecoder(r.Body).Decode(&bodyParams)
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
 
-	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Bad Request")
+	// The teacher acting is the authenticated one
+	teacher, ok := c.actingTeacher(w, r, bodyParams.Teacher)
+	if !ok {
 		return
 	}
 
-	// Checks if teacher is in db
-	var teacher models.Teacher
-	result := models.DB.Where("email = ?", bodyParams.Teacher).First(&teacher)
+	term, ok := c.findOpenTerm(w, bodyParams.Term)
+	if !ok {
+		return
+	}
 
-	if result.Error != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid teacher's email")
+	if bodyParams.Class != "" {
+		class, ok := c.teacherClass(w, teacher.Email, bodyParams.Class)
+		if !ok {
+			return
+		}
+		before := c.auditedStudents(term, teacher.Email, class)
+		if err := c.enrollStudents(term, class, bodyParams.Students); err != nil {
+			log.Println(err)
+			utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating db")
+			return
+		}
+		c.audit(r, "students.register", teacher.Email, before, c.auditedStudents(term, teacher.Email, class))
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	before := c.auditedStudents(term, teacher.Email, nil)
+
 	// Filter out invalid students (or students not in the db)
 	m := make(map[string]bool) // Prevent duplicates
 	for _, student := range bodyParams.Students {
-		if CheckStudentExists(student) {
+		if c.CheckStudentExists(student) {
 			if m[student] {
 				continue
 			}
 			m[student] = true
-			newPair := &models.Registry{
-				TeacherEmail: teacher.Email,
-				StudentEmail: student,
-				CreatedAt:    time.Now(),
-				UpdatedAt:    time.Now(),
-			}
-			// Search for teacher_student pair, if it does not exist, we create a new entry in the db
-			res := models.DB.Where("teacher_email = ? AND student_email = ?", teacher.Email, student).FirstOrCreate(&newPair)
-			if res.Error != nil {
-				log.Println(res.Error)
+			// Creates the teacher_student pair only if it does not exist
+			if err := c.Store.CreateRegistry(term.ID, teacher.Email, student); err != nil {
+				log.Println(err)
 			}
 		}
 	}
 
+	c.audit(r, "students.register", teacher.Email, before, c.auditedStudents(term, teacher.Email, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Removes one/more students from a specified teacher (every class of the teacher), or from one of the teacher's classes
+func (c *Controller) DeregisterStudents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var bodyParams DeregisterStudentsRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	// The teacher acting is the authenticated one
+	teacher, ok := c.actingTeacher(w, r, bodyParams.Teacher)
+	if !ok {
+		return
+	}
+
+	term, ok := c.findOpenTerm(w, bodyParams.Term)
+	if !ok {
+		return
+	}
+
+	var class *models.Class
+	if bodyParams.Class != "" {
+		if class, ok = c.teacherClass(w, teacher.Email, bodyParams.Class); !ok {
+			return
+		}
+	}
+
+	before := c.auditedStudents(term, teacher.Email, class)
+
+	// Students which are not registered under the teacher are skipped
+	for _, student := range bodyParams.Students {
+		var err error
+		if class != nil {
+			err = c.Store.DeleteEnrollment(term.ID, class.Code, student)
+		} else {
+			err = c.Store.DeleteRegistry(term.ID, teacher.Email, student)
+		}
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			log.Println(err)
+			utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating db")
+			return
+		}
+	}
+
+	c.audit(r, "students.deregister", teacher.Email, before, c.auditedStudents(term, teacher.Email, class))
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Gets the common students given a list of teachers as query params
-func GetCommonStudents(w http.ResponseWriter, r *http.Request) {
+// Gets the students common to a list of teachers (or of classes) given as query params, in the current term by default.
+// The `mode` query param picks the students registered with all (default), any, at least N (min=N) or only the listed teachers.
+// The students are returned a page at a time, optionally filtered by the `suspended` query param.
+func (c *Controller) GetCommonStudents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	p, ok := parsePage(w, r, "email", "email")
+	if !ok {
+		return
+	}
+
 	// Retrieves the query params corresponding to teacher into an array of strings
-	teachers := []string(r.URL.Query()["teacher"])
+	query := r.URL.Query()
+	teachers := []string(query["teacher"])
+	classes := []string(query["class"])
 
-	// Filter teachers query params to ensure that only unique fields exist
+	if len(teachers) > 0 && len(classes) > 0 {
+		invalidField(w, "class", "excluded_with", "Use either teacher or class query params")
+		return
+	}
+	if len(teachers) == 0 && len(classes) == 0 {
+		invalidField(w, "teacher", "required_without", "At least one teacher or class query param is required")
+		return
+	}
+
+	// Filter the query params to ensure that only unique fields exist (emails and codes are case insensitive)
 	var m = make(map[string]bool)
-	var filteredTeachers = []string{}
+	var listed = []string{}
 
-	for _, teacher := range teachers {
-		if m[teacher] {
+	for _, value := range append(teachers, classes...) {
+		value = strings.ToLower(value)
+		if m[value] {
 			continue
 		}
-		m[teacher] = true
-		filteredTeachers = append(filteredTeachers, teacher)
+		m[value] = true
+		listed = append(listed, value)
 	}
 
-	// Stores the common students' email into an array of strings within the response struct
-	var commonStudents CommonStudentsResponse
+	mode, ok := parseMatchMode(query.Get("mode"), len(listed))
+	if !ok {
+		invalidField(w, "mode", "oneof", "Invalid mode")
+		return
+	}
+	term, ok := c.findTerm(w, query.Get("term"))
+	if !ok {
+		return
+	}
 
-	// Execute a query on the DB to retrieve all common students
-	res := models.DB.Model(&models.Registry{}).Select("student_email").
-		Where("teacher_email IN ?", filteredTeachers).
-		Group("student_email").
-		Having("COUNT(DISTINCT teacher_email) = ?", len(filteredTeachers)).
-		Pluck("student_email", &commonStudents.Students)
+	// Retrieve every teacher (or class) of the students related to the listed ones
+	var related map[string][]string
+	var err error
+	if len(classes) > 0 {
+		related, err = c.Store.GetStudentsClasses(term.ID, listed)
+	} else {
+		related, err = c.Store.GetStudentsTeachers(term.ID, listed)
+	}
 
-	if res.Error != nil {
-		if len(commonStudents.Students) == 0 {
-			json.NewEncoder(w).Encode(commonStudents)
-			return
-		}
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving common students")
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving common students")
 		return
 	}
 
-	json.NewEncoder(w).Encode(commonStudents)
-}
+	students := []string{}
+	matches := make(map[string][]string)
+	for student, values := range related {
+		matched := []string{}
+		for _, value := range values {
+			if m[strings.ToLower(value)] {
+				matched = append(matched, value)
+			}
+		}
+		if mode.includes(len(matched), len(listed), len(values)) {
+			students = append(students, student)
+			matches[student] = matched
+		}
+	}
+	if students, ok = c.filterSuspended(w, r, students); !ok {
+		return
+	}
 
-// Updates the student's suspend status to either 0 or 1
-func UpdateStudentSuspendStatus(value int, student *models.Student) bool {
-	// 0 => Not Suspended, 1 => Suspended
-	result := models.DB.Model(&student).Update("suspended", value)
-	if result.Error != nil {
-		log.Println(result.Error)
-		return false
+	var commonStudents CommonStudentsResponse
+	commonStudents.Students, commonStudents.NextCursor = paginateStrings(students, p)
+	if query.Get("matches") == "true" {
+		commonStudents.Matches = make(map[string][]string)
+		for _, student := range commonStudents.Students {
+			commonStudents.Matches[student] = matches[student]
+		}
 	}
-	return true
+
+	json.NewEncoder(w).Encode(commonStudents)
 }
 
-// Suspends a student
-func SuspendStudent(w http.ResponseWriter, r *http.Request) {
+// Suspends a student, optionally for a limited period of time
+func (c *Controller) SuspendStudent(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var bodyParams SuspendStudentRequest
-	err := json.NewDecoder(r.Body).Decode(&bodyParams)
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	// Checks if student is in db
+	student, err := c.Store.GetStudent(bodyParams.Student)
 
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Bad Request")
+		utils.RespondWithError(w, http.StatusBadRequest, codeStudentNotFound, "Invalid student's email")
 		return
 	}
 
-	// Checks if student is in db
-	var student models.Student
-	res := models.DB.Where("email = ?", bodyParams.Student).First(&student)
+	now := time.Now()
+	startsAt := now
+	if bodyParams.StartsAt != nil {
+		startsAt = *bodyParams.StartsAt
+	}
 
-	if res.Error != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid student's email")
+	if bodyParams.EndsAt != nil && (!bodyParams.EndsAt.After(startsAt) || !bodyParams.EndsAt.After(now)) {
+		invalidField(w, "ends_at", "gtfield", "Suspension must end after it starts and in the future")
 		return
 	}
 
-	// Update the suspended field in student table to 1 => means suspended
-	if !UpdateStudentSuspendStatus(1, &student) {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating db")
+	before := c.auditedSuspensions(student.Email)
+
+	// Record the suspension in the student's history
+	event := models.SuspensionEvent{
+		StudentEmail: student.Email,
+		Action:       models.SuspendAction,
+		Actor:        actor(r),
+		Reason:       bodyParams.Reason,
+		StartsAt:     &startsAt,
+		EndsAt:       bodyParams.EndsAt,
+	}
+	if err := c.Store.CreateSuspensionEvent(&event); err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating db")
 		return
 	}
 
+	c.audit(r, "students.suspend", student.Email, before, c.auditedSuspensions(student.Email))
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Un-suspend a student
-func UnSuspendStudent(w http.ResponseWriter, r *http.Request) {
+// Un-suspend a student, lifts their current and upcoming suspensions
+func (c *Controller) UnSuspendStudent(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var bodyParams SuspendStudentRequest
-	err := json.NewDecoder(r.Body).Decode(&bodyParams)
-
-	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Bad Request")
+	var bodyParams UnSuspendStudentRequest
+	if !decodeBody(w, r, &bodyParams) {
 		return
 	}
 
 	// Checks if student is in db
-	var student models.Student
-	res := models.DB.Where("email = ?", bodyParams.Student).First(&student)
+	student, err := c.Store.GetStudent(bodyParams.Student)
 
-	if res.Error != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid student's email")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, codeStudentNotFound, "Invalid student's email")
 		return
 	}
 
-	// Update the suspended field in student table to 1 => means suspended
-	if !UpdateStudentSuspendStatus(0, &student) {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating db")
+	before := c.auditedSuspensions(student.Email)
+
+	// Record the un-suspension in the student's history
+	event := models.SuspensionEvent{
+		StudentEmail: student.Email,
+		Action:       models.UnSuspendAction,
+		Actor:        actor(r),
+		Reason:       bodyParams.Reason,
+	}
+	if err := c.Store.CreateSuspensionEvent(&event); err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating db")
 		return
 	}
 
+	c.audit(r, "students.unsuspend", student.Email, before, c.auditedSuspensions(student.Email))
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func CheckStudentSuspended(email string) bool {
-	var student models.Student
-	err := models.DB.Where("email = ?", email).First(&student).Error
-	if err != nil {
-		return true
-	}
-	return student.Suspended == 1
+// Checks if a student is currently suspended, students which do not exist are treated as suspended
+func (c *Controller) CheckStudentSuspended(email string) bool {
+	return notifications.IsSuspended(c.Store, email, time.Now())
 }
 
 // Retrieve list of students who can receive a given notification
 // remove suspended student
 // registered under teacher OR @mentioned
-func GetStudentsWithNotification(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) GetStudentsWithNotification(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var bodyParams GetStudentsWithNotificationRequest
-	err := json.NewDecoder(r.Body).Decode(&bodyParams)
-
-	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Bad Request")
+	if !decodeBody(w, r, &bodyParams) {
 		return
 	}
 
-	// Checks if teacher is in db
-	var teacher models.Teacher
-	result := models.DB.Where("email = ?", bodyParams.Teacher).First(&teacher)
-
-	if result.Error != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid teacher's email")
+	// The teacher acting is the authenticated one
+	teacher, ok := c.actingTeacher(w, r, bodyParams.Teacher)
+	if !ok {
 		return
 	}
 
-	// Retrieve the matching regex for student emails in notification (@ mentioned students)
-	regexPattern := regexp.MustCompile(`@\w+@\w+\.\w+`)
-	studentEmails := regexPattern.FindAllString(bodyParams.Notification, -1)
-
-	// Retrieve students registered under the teacher
-	var registeredStudents []models.Registry
-	res := models.DB.Where("teacher_email = ?", teacher.Email).Find(&registeredStudents)
+	// The current term (if any) is used by default
+	var termID uint
+	if bodyParams.Term != "" {
+		term, ok := c.findTerm(w, bodyParams.Term)
+		if !ok {
+			return
+		}
+		termID = term.ID
+	}
 
-	if res.Error != nil {
-		log.Println(res.Error)
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving registered students")
+	// Resolve the recipients, keep a record of the notification and queue its deliveries
+	notification := models.Notification{
+		ID:           utils.NewID(),
+		TeacherEmail: teacher.Email,
+		Text:         bodyParams.Notification,
+	}
+	resolution, err := notifications.Send(c.Resolver, &notification, termID, time.Now())
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error sending notification")
 		return
 	}
-
-	// Check for duplicates and remove the first '@' character in front of emails
-	m := make(map[string]bool)
-	var filteredStudents GetStudentsWithNotificationResponse
-
-	// Loop through @ mentioned students and add in those not suspended
-	for _, s := range studentEmails {
-		if !CheckStudentSuspended(s[1:]) {
-			if m[s[1:]] {
-				continue
-			}
-			m[s[1:]] = true
-			filteredStudents.Recipients = append(filteredStudents.Recipients, s[1:])
-		}
+	c.audit(r, "notifications.send", notification.ID, nil, notification)
+	w.Header().Set("Location", "/api/notifications/"+notification.ID)
+
+	response := GetStudentsWithNotificationResponse{
+		Recipients:        resolution.Recipients,
+		UnknownMentions:   resolution.UnknownMentions,
+		SuspendedMentions: resolution.SuspendedMentions,
+		UnknownGroups:     resolution.UnknownGroups,
 	}
-
-	// Loop through registered students and add in those not suspended
-	for _, student := range registeredStudents {
-		if !CheckStudentSuspended(student.StudentEmail) {
-			if m[student.StudentEmail] {
-				continue
-			}
-			m[student.StudentEmail] = true
-			filteredStudents.Recipients = append(filteredStudents.Recipients, student.StudentEmail)
-		}
+	if r.URL.Query().Get("explain") == "true" {
+		response.Explanations = resolution.Candidates
 	}
-
-	json.NewEncoder(w).Encode(filteredStudents)
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bensohh/go-admin/auth"
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+	"github.com/gorilla/mux"
+)
+
+type CreateAPIKeyRequest struct {
+	// Tells the keys apart, e.g. the script using the key
+	Name string `json:"name" validate:"max=255"`
+}
+
+type CreateAPIKeyResponse struct {
+	// Only returned when the key is created, only its hash is stored
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+type APIKeysResponse struct {
+	APIKeys    []models.APIKey `json:"api_keys"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// Creates an API key for the authenticated teacher
+func (c *Controller) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var bodyParams CreateAPIKeyRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	key, apiKey, err := auth.IssueAPIKey(c.Store, teacher.Email, strings.TrimSpace(bodyParams.Name))
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error creating API key")
+		return
+	}
+
+	c.audit(r, "apikeys.create", strconv.FormatUint(uint64(apiKey.ID), 10), nil, apiKey)
+	utils.RespondWithJSON(w, http.StatusCreated, CreateAPIKeyResponse{Key: key, APIKey: *apiKey})
+}
+
+// Lists the API keys of the authenticated teacher (revoked ones included), a page at a time
+func (c *Controller) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "created_at", "created_at")
+	if !ok {
+		return
+	}
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	keys, next, err := c.Store.ListAPIKeys(teacher.Email, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving API keys")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, APIKeysResponse{APIKeys: keys, NextCursor: nextCursor(p, next)})
+}
+
+// Revokes one of the API keys of the authenticated teacher
+func (c *Controller) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, codeAPIKeyNotFound, "API key not found")
+		return
+	}
+
+	err = c.Store.RevokeAPIKey(teacher.Email, uint(id), time.Now())
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeAPIKeyNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error revoking API key")
+		return
+	}
+
+	c.audit(r, "apikeys.revoke", mux.Vars(r)["id"], nil, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+)
+
+type AuditEntriesResponse struct {
+	Entries    []models.AuditEntry `json:"entries"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// Records the action of the authenticated teacher in the audit log, see auditAs
+func (c *Controller) audit(r *http.Request, action string, target string, before interface{}, after interface{}) {
+	c.auditAs(r, actor(r), action, target, before, after)
+}
+
+// Records an action in the audit log along with the state of its target before and after (nil if it did not exist).
+// The action already took effect, so a failure is logged rather than failing the request.
+func (c *Controller) auditAs(r *http.Request, actor string, action string, target string, before interface{}, after interface{}) {
+	entry := models.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		RequestID: utils.RequestID(r.Context()),
+		Before:    auditValue(before),
+		After:     auditValue(after),
+	}
+	if err := c.Store.CreateAuditEntry(&entry); err != nil {
+		log.Printf("Unable to record audit entry %s %s by %s: %v", action, target, actor, err)
+	}
+}
+
+func auditValue(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return data
+}
+
+// The students registered under a teacher (or enrolled in one of their classes) as recorded in the audit log
+type auditedRegistry struct {
+	Term     string   `json:"term"`
+	Class    string   `json:"class,omitempty"`
+	Students []string `json:"students"`
+}
+
+// Snapshots the students registered under the teacher in the term, or enrolled in the class if given
+func (c *Controller) auditedStudents(term *models.Term, teacherEmail string, class *models.Class) auditedRegistry {
+	registry := auditedRegistry{Term: term.Name}
+	var err error
+	if class != nil {
+		registry.Class = class.Code
+		registry.Students, err = c.Store.GetClassStudents(term.ID, class.Code)
+	} else {
+		registry.Students, err = c.Store.GetRegisteredStudents(term.ID, teacherEmail)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	if registry.Students == nil {
+		registry.Students = []string{}
+	}
+	return registry
+}
+
+// A student's suspension state as recorded in the audit log
+type auditedSuspension struct {
+	Suspended   bool                `json:"suspended"`
+	Suspensions []models.Suspension `json:"suspensions"`
+}
+
+// Snapshots the suspension history of the student
+func (c *Controller) auditedSuspensions(studentEmail string) auditedSuspension {
+	events, err := c.Store.ListSuspensionEvents(studentEmail)
+	if err != nil {
+		log.Println(err)
+	}
+	suspensions := models.DeriveSuspensions(events)
+	if suspensions == nil {
+		suspensions = []models.Suspension{}
+	}
+	return auditedSuspension{
+		Suspended:   models.ActiveSuspension(suspensions, time.Now()) != nil,
+		Suspensions: suspensions,
+	}
+}
+
+// Parses the `actor`, `action`, `target`, `request_id`, `from` and `to` query params, responds with an error if invalid
+func auditFilter(w http.ResponseWriter, r *http.Request) (*models.AuditFilter, bool) {
+	query := r.URL.Query()
+
+	from, err := parseTimeParam(query.Get("from"), false)
+	if err != nil {
+		invalidField(w, "from", "datetime", "Invalid from date")
+		return nil, false
+	}
+	to, err := parseTimeParam(query.Get("to"), true)
+	if err != nil {
+		invalidField(w, "to", "datetime", "Invalid to date")
+		return nil, false
+	}
+
+	return &models.AuditFilter{
+		Actor:     query.Get("actor"),
+		Action:    query.Get("action"),
+		Target:    query.Get("target"),
+		RequestID: query.Get("request_id"),
+		From:      from,
+		To:        to,
+	}, true
+}
+
+// Lists the audit entries matching the filter query params, a page at a time, newest first by default
+func (c *Controller) ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "-created_at", "created_at")
+	if !ok {
+		return
+	}
+	filter, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	entries, next, err := c.Store.ListAuditEntries(*filter, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving audit entries")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, AuditEntriesResponse{Entries: entries, NextCursor: nextCursor(p, next)})
+}
+
+// Exports every audit entry matching the filter query params as JSON Lines, oldest first
+func (c *Controller) ExportAuditEntries(w http.ResponseWriter, r *http.Request) {
+	filter, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	entries, _, err := c.Store.ListAuditEntries(*filter, nil)
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving audit entries")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
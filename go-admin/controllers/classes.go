@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/bensohh/go-admin/auth"
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+	"github.com/gorilla/mux"
+)
+
+type CreateClassRequest struct {
+	Code     string   `json:"code" validate:"required,group_name"`
+	Name     string   `json:"name" validate:"max=255"`
+	Teachers []string `json:"teachers" validate:"max=100,dive,email"`
+}
+
+// Only the fields which are set are updated, teachers replaces every teacher of the class
+type UpdateClassRequest struct {
+	Name     *string   `json:"name" validate:"omitempty,max=255"`
+	Teachers *[]string `json:"teachers" validate:"omitempty,max=100,dive,email"`
+}
+
+type EnrollStudentsRequest struct {
+	// Name of the term, the current term is used if not set
+	Term     string   `json:"term,omitempty" validate:"max=64"`
+	Students []string `json:"students" validate:"required,min=1,max=1000,dive,email"`
+}
+
+type ClassesResponse struct {
+	Classes    []models.Class `json:"classes"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type ClassStudentsResponse struct {
+	Students   []string `json:"students"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type StudentClassesResponse struct {
+	Classes    []string `json:"classes"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// Checks that every teacher exists
+func (c *Controller) checkTeachersExist(teachers []string) bool {
+	for _, teacher := range teachers {
+		if _, err := c.Store.GetTeacher(teacher); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Retrieves the class of the `code` route variable, responds with an error if it cannot be found
+func (c *Controller) findClass(w http.ResponseWriter, r *http.Request) (*models.Class, bool) {
+	class, err := c.Store.GetClass(mux.Vars(r)["code"])
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeClassNotFound, "Class not found")
+		return nil, false
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving class")
+		return nil, false
+	}
+	return class, true
+}
+
+// Same as findClass, also responds with an error if the class is not taught by the authenticated teacher,
+// unless they are an admin
+func (c *Controller) findOwnClass(w http.ResponseWriter, r *http.Request) (*models.Class, bool) {
+	class, ok := c.findClass(w, r)
+	if !ok {
+		return nil, false
+	}
+	if principal := auth.FromContext(r.Context()); principal == nil || (principal.Role != models.RoleAdmin && !slices.Contains(class.Teachers, principal.TeacherEmail)) {
+		utils.RespondWithError(w, http.StatusForbidden, codeNotOwnClass, "Class is not taught by the teacher")
+		return nil, false
+	}
+	return class, true
+}
+
+// Creates a new class
+func (c *Controller) CreateClass(w http.ResponseWriter, r *http.Request) {
+	var bodyParams CreateClassRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	// The code is used to @mention the class, e.g. @class:3A
+	code := strings.TrimSpace(bodyParams.Code)
+	if !c.checkTeachersExist(bodyParams.Teachers) {
+		utils.RespondWithError(w, http.StatusBadRequest, codeTeacherNotFound, "Invalid teacher's email")
+		return
+	}
+
+	class := models.Class{Code: code, Name: bodyParams.Name, Teachers: bodyParams.Teachers}
+	err := c.Store.CreateClass(&class)
+
+	if errors.Is(err, models.ErrDuplicate) {
+		utils.RespondWithError(w, http.StatusConflict, codeClassExists, "Class already exists")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error creating class")
+		return
+	}
+
+	c.audit(r, "classes.create", class.Code, nil, class)
+	utils.RespondWithJSON(w, http.StatusCreated, class)
+}
+
+// Lists every class, or the classes taught by the `teacher` query param, a page at a time.
+// The classes can be filtered by the `name_prefix` query param.
+func (c *Controller) ListClasses(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "code", "code", "name", "created_at")
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	classes, next, err := c.Store.ListClasses(models.ClassFilter{
+		TeacherEmail: query.Get("teacher"),
+		NamePrefix:   query.Get("name_prefix"),
+	}, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving classes")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, ClassesResponse{Classes: classes, NextCursor: nextCursor(p, next)})
+}
+
+// Gets a class by code along with its teachers
+func (c *Controller) GetClass(w http.ResponseWriter, r *http.Request) {
+	class, ok := c.findClass(w, r)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, class)
+}
+
+// Updates a class's name and teachers
+func (c *Controller) UpdateClass(w http.ResponseWriter, r *http.Request) {
+	var bodyParams UpdateClassRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	class, ok := c.findClass(w, r)
+	if !ok {
+		return
+	}
+	before := *class
+
+	if bodyParams.Name != nil {
+		class.Name = *bodyParams.Name
+	}
+	if bodyParams.Teachers != nil {
+		if !c.checkTeachersExist(*bodyParams.Teachers) {
+			utils.RespondWithError(w, http.StatusBadRequest, codeTeacherNotFound, "Invalid teacher's email")
+			return
+		}
+		class.Teachers = *bodyParams.Teachers
+	}
+
+	err := c.Store.UpdateClass(class)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeClassNotFound, "Class not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating class")
+		return
+	}
+
+	c.audit(r, "classes.update", class.Code, before, class)
+	utils.RespondWithJSON(w, http.StatusOK, class)
+}
+
+// Deletes a class, its enrollments are deleted as well
+func (c *Controller) DeleteClass(w http.ResponseWriter, r *http.Request) {
+	before, _ := c.Store.GetClass(mux.Vars(r)["code"])
+	err := c.Store.DeleteClass(mux.Vars(r)["code"])
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeClassNotFound, "Class not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error deleting class")
+		return
+	}
+
+	c.audit(r, "classes.delete", mux.Vars(r)["code"], before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the students enrolled in a class during the `term` query param (the current term by default),
+// a page at a time, optionally filtered by the `suspended` query param
+func (c *Controller) GetClassStudents(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "email", "email")
+	if !ok {
+		return
+	}
+	class, ok := c.findClass(w, r)
+	if !ok {
+		return
+	}
+	term, ok := c.findTerm(w, r.URL.Query().Get("term"))
+	if !ok {
+		return
+	}
+
+	students, err := c.Store.GetClassStudents(term.ID, class.Code)
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving enrolled students")
+		return
+	}
+	if students, ok = c.filterSuspended(w, r, students); !ok {
+		return
+	}
+
+	var response ClassStudentsResponse
+	response.Students, response.NextCursor = paginateStrings(students, p)
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// Enrolls one/more students in a class, students which do not exist are skipped
+func (c *Controller) EnrollStudents(w http.ResponseWriter, r *http.Request) {
+	var bodyParams EnrollStudentsRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	class, ok := c.findOwnClass(w, r)
+	if !ok {
+		return
+	}
+	term, ok := c.findOpenTerm(w, bodyParams.Term)
+	if !ok {
+		return
+	}
+
+	before := c.auditedStudents(term, "", class)
+	if err := c.enrollStudents(term, class, bodyParams.Students); err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating db")
+		return
+	}
+
+	c.audit(r, "classes.enroll", class.Code, before, c.auditedStudents(term, "", class))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Enrolls the existing students in the class during the term, the others are skipped
+func (c *Controller) enrollStudents(term *models.Term, class *models.Class, students []string) error {
+	for _, student := range students {
+		if !c.CheckStudentExists(student) {
+			continue
+		}
+		// Creates the enrollment only if it does not exist
+		if err := c.Store.CreateEnrollment(term.ID, class.Code, student); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes a student from a class during the `term` query param (the current term by default)
+func (c *Controller) UnenrollStudent(w http.ResponseWriter, r *http.Request) {
+	email, ok := enrollmentEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	class, ok := c.findOwnClass(w, r)
+	if !ok {
+		return
+	}
+	term, ok := c.findOpenTerm(w, r.URL.Query().Get("term"))
+	if !ok {
+		return
+	}
+
+	before := c.auditedStudents(term, "", class)
+	err := c.Store.DeleteEnrollment(term.ID, class.Code, email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeEnrollmentNotFound, "Enrollment not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating db")
+		return
+	}
+
+	c.audit(r, "classes.unenroll", class.Code, before, c.auditedStudents(term, "", class))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the classes a student is enrolled in during the `term` query param (the current term by default),
+// a page at a time
+func (c *Controller) GetStudentClasses(w http.ResponseWriter, r *http.Request) {
+	email, ok := studentEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	p, ok := parsePage(w, r, "code", "code")
+	if !ok {
+		return
+	}
+
+	student, err := c.Store.GetStudent(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving student")
+		return
+	}
+
+	term, ok := c.findTerm(w, r.URL.Query().Get("term"))
+	if !ok {
+		return
+	}
+
+	classes, err := c.Store.GetStudentClasses(term.ID, student.Email)
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving classes")
+		return
+	}
+
+	var response StudentClassesResponse
+	response.Classes, response.NextCursor = paginateStrings(classes, p)
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/bensohh/go-admin/utils"
+)
+
+// Error codes of the responses, along with the generic ones of the utils package (e.g. VALIDATION_FAILED)
+const (
+	codeRouteNotFound    = "ROUTE_NOT_FOUND"
+	codeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	// The request body is larger than maxBodyBytes
+	codeRequestTooLarge = "REQUEST_TOO_LARGE"
+
+	codeTeacherNotFound      = "TEACHER_NOT_FOUND"
+	codeStudentNotFound      = "STUDENT_NOT_FOUND"
+	codeClassNotFound        = "CLASS_NOT_FOUND"
+	codeTermNotFound         = "TERM_NOT_FOUND"
+	codeEnrollmentNotFound   = "ENROLLMENT_NOT_FOUND"
+	codeNotificationNotFound = "NOTIFICATION_NOT_FOUND"
+	codeJobNotFound          = "JOB_NOT_FOUND"
+	codeAPIKeyNotFound       = "API_KEY_NOT_FOUND"
+	codeSessionNotFound      = "SESSION_NOT_FOUND"
+
+	codeTeacherExists = "TEACHER_ALREADY_EXISTS"
+	codeStudentExists = "STUDENT_ALREADY_EXISTS"
+	codeClassExists   = "CLASS_ALREADY_EXISTS"
+	codeTermExists    = "TERM_ALREADY_EXISTS"
+
+	// There is no current term to default to
+	codeNoCurrentTerm = "NO_CURRENT_TERM"
+	// Archived terms are read only
+	codeTermArchived = "TERM_ARCHIVED"
+	// Only the current term can be rolled over
+	codeTermNotCurrent = "TERM_NOT_CURRENT"
+	// Only the scheduled notifications can be rescheduled or cancelled
+	codeNotificationNotScheduled = "NOTIFICATION_NOT_SCHEDULED"
+	// Only the dead jobs can be retried
+	codeJobNotDead = "JOB_NOT_DEAD"
+	// The suspension history is kept, the students who have one cannot be deleted
+	codeStudentHasSuspensions = "STUDENT_HAS_SUSPENSIONS"
+	// The notification history is kept, the teachers who sent notifications cannot be deleted
+	codeTeacherHasNotifications = "TEACHER_HAS_NOTIFICATIONS"
+
+	codeInvalidCredentials  = "INVALID_CREDENTIALS"
+	codeInvalidRefreshToken = "INVALID_REFRESH_TOKEN"
+	codeInvalidResetToken   = "INVALID_RESET_TOKEN"
+	// The route only applies to the requests authenticated with a session
+	codeSessionRequired = "SESSION_REQUIRED"
+	// Wrong or already used TOTP or recovery code
+	codeInvalidTwoFactorCode = "INVALID_TWO_FACTOR_CODE"
+	codeTwoFactorEnabled     = "TWO_FACTOR_ALREADY_ENABLED"
+	codeTwoFactorNotEnabled  = "TWO_FACTOR_NOT_ENABLED"
+	// Too many codes were rejected in a row, see auth.MaxTwoFactorFailures
+	codeTwoFactorLocked = "TWO_FACTOR_LOCKED"
+
+	// The role of the caller does not have the permission of the route
+	codeInsufficientRole = "INSUFFICIENT_ROLE"
+	// A teacher tried to act as another teacher
+	codeNotOwnTeacher = "NOT_OWN_TEACHER"
+	// A teacher tried to use a class they do not teach
+	codeNotOwnClass = "NOT_OWN_CLASS"
+	// An admin route was called without passing the two-factor authentication
+	codeTwoFactorRequired = "TWO_FACTOR_REQUIRED"
+)
+
+// Responds with a VALIDATION_FAILED problem for a single invalid field (or query param) breaking the rule
+func invalidField(w http.ResponseWriter, field string, rule string, detail string) {
+	utils.RespondWithValidationError(w, detail, utils.FieldError{Field: field, Code: rule, Detail: detail})
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bensohh/go-admin/jobs"
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+	"github.com/gorilla/mux"
+)
+
+type JobsResponse struct {
+	Jobs       []models.Job `json:"jobs"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// Lists the background jobs with the given `status` query param, the dead jobs by default, a page at a time
+func (c *Controller) ListJobs(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "created_at", "created_at", "run_at")
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.JobDead
+	}
+
+	switch status {
+	case models.JobQueued, models.JobRunning, models.JobSucceeded, models.JobDead:
+	default:
+		invalidField(w, "status", "oneof", "Invalid job status")
+		return
+	}
+
+	list, next, err := c.Store.ListJobs(status, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving jobs")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, JobsResponse{Jobs: list, NextCursor: nextCursor(p, next)})
+}
+
+// Puts a dead job back in the queue
+func (c *Controller) RetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, codeJobNotFound, "Job not found")
+		return
+	}
+
+	before, _ := c.Store.GetJob(uint(id))
+	job, err := jobs.Retry(c.Store, uint(id))
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeJobNotFound, "Job not found")
+		return
+	}
+	if errors.Is(err, jobs.ErrNotDead) {
+		utils.RespondWithError(w, http.StatusConflict, codeJobNotDead, "Only dead jobs can be retried")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrying job")
+		return
+	}
+
+	c.audit(r, "jobs.retry", mux.Vars(r)["id"], before, job)
+	utils.RespondWithJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bensohh/go-admin/auth"
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/notifications"
+	"github.com/bensohh/go-admin/utils"
+	"github.com/gorilla/mux"
+)
+
+type CreateNotificationRequest struct {
+	// Optional, must be the authenticated teacher if set unless they are an admin
+	Teacher      string     `json:"teacher,omitempty" validate:"omitempty,email"`
+	Notification string     `json:"notification" validate:"required,max=10000"`
+	SendAt       *time.Time `json:"send_at,omitempty"` // Sent right away if not set or in the past
+}
+
+type RescheduleNotificationRequest struct {
+	SendAt *time.Time `json:"send_at" validate:"required"`
+}
+
+type NotificationsResponse struct {
+	Notifications []models.Notification `json:"notifications"`
+	NextCursor    string                `json:"next_cursor,omitempty"`
+}
+
+type RecipientsResponse struct {
+	Recipients []string `json:"recipients"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type DeliveriesResponse struct {
+	Deliveries []models.Delivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// Parses a `from`/`to` query param, either a RFC 3339 timestamp or a date.
+// A `to` date covers the whole day.
+func parseTimeParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// Lists the notifications, filtered by teacher, status and date range (`teacher`, `status`, `from`, `to` query params),
+// a page at a time, newest first by default
+func (c *Controller) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "-created_at", "created_at", "send_at")
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	from, err := parseTimeParam(query.Get("from"), false)
+	if err != nil {
+		invalidField(w, "from", "datetime", "Invalid from date")
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"), true)
+	if err != nil {
+		invalidField(w, "to", "datetime", "Invalid to date")
+		return
+	}
+	status := query.Get("status")
+	switch status {
+	case "", models.NotificationScheduled, models.NotificationSent, models.NotificationCancelled:
+	default:
+		invalidField(w, "status", "oneof", "Invalid notification status")
+		return
+	}
+
+	// The teachers only list their own notifications
+	teacher := query.Get("teacher")
+	if readsOwnHistoryOnly(r) {
+		acting, ok := c.actingTeacher(w, r, teacher)
+		if !ok {
+			return
+		}
+		teacher = acting.Email
+	}
+
+	list, next, err := c.Store.ListNotifications(models.NotificationFilter{
+		TeacherEmail: teacher,
+		Status:       status,
+		From:         from,
+		To:           to,
+	}, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving notifications")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, NotificationsResponse{Notifications: list, NextCursor: nextCursor(p, next)})
+}
+
+// Sends a notification, or schedules it if `send_at` is in the future
+func (c *Controller) CreateNotification(w http.ResponseWriter, r *http.Request) {
+	var bodyParams CreateNotificationRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	// The teacher acting is the authenticated one
+	teacher, ok := c.actingTeacher(w, r, bodyParams.Teacher)
+	if !ok {
+		return
+	}
+
+	notification := models.Notification{
+		ID:           utils.NewID(),
+		TeacherEmail: teacher.Email,
+		Text:         bodyParams.Notification,
+	}
+
+	now := time.Now()
+	status := http.StatusCreated
+	var err error
+	if bodyParams.SendAt != nil && bodyParams.SendAt.After(now) {
+		err = notifications.Schedule(c.Store, &notification, *bodyParams.SendAt)
+		status = http.StatusAccepted
+	} else {
+		_, err = notifications.Send(c.Resolver, &notification, 0, now)
+	}
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error saving notification")
+		return
+	}
+
+	c.audit(r, "notifications.create", notification.ID, nil, notification)
+	w.Header().Set("Location", "/api/notifications/"+notification.ID)
+	utils.RespondWithJSON(w, status, notification)
+}
+
+// Moves a scheduled notification to another time
+func (c *Controller) RescheduleNotification(w http.ResponseWriter, r *http.Request) {
+	var bodyParams RescheduleNotificationRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+	if !bodyParams.SendAt.After(time.Now()) {
+		invalidField(w, "send_at", "gt", "send_at must be in the future")
+		return
+	}
+
+	notification, ok := c.findNotification(w, r)
+	if !ok {
+		return
+	}
+	before := *notification
+
+	err := notifications.Reschedule(c.Store, notification, *bodyParams.SendAt)
+	c.respondScheduleChange(w, r, "notifications.reschedule", before, notification, err)
+}
+
+// Cancels a scheduled notification
+func (c *Controller) CancelNotification(w http.ResponseWriter, r *http.Request) {
+	notification, ok := c.findNotification(w, r)
+	if !ok {
+		return
+	}
+	before := *notification
+
+	err := notifications.Cancel(c.Store, notification)
+	c.respondScheduleChange(w, r, "notifications.cancel", before, notification, err)
+}
+
+// Retrieves the notification of the `id` route variable, responds with an error if it cannot be found.
+// The notifications of other teachers are not found for a teacher, whether they read or change them.
+func (c *Controller) findNotification(w http.ResponseWriter, r *http.Request) (*models.Notification, bool) {
+	notification, err := c.Store.GetNotification(mux.Vars(r)["id"])
+
+	if err == nil && readsOwnHistoryOnly(r) && auth.FromContext(r.Context()).TeacherEmail != notification.TeacherEmail {
+		err = models.ErrNotFound
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeNotificationNotFound, "Notification not found")
+		return nil, false
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving notification")
+		return nil, false
+	}
+	return notification, true
+}
+
+func (c *Controller) respondScheduleChange(w http.ResponseWriter, r *http.Request, action string, before models.Notification, notification *models.Notification, err error) {
+	if errors.Is(err, notifications.ErrNotScheduled) {
+		utils.RespondWithError(w, http.StatusConflict, codeNotificationNotScheduled, "Notification is not scheduled")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating notification")
+		return
+	}
+
+	c.audit(r, action, notification.ID, before, notification)
+	utils.RespondWithJSON(w, http.StatusOK, notification)
+}
+
+// Gets a notification along with its recipients
+func (c *Controller) GetNotification(w http.ResponseWriter, r *http.Request) {
+	notification, ok := c.findNotification(w, r)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, notification)
+}
+
+// Lists the recipients of a notification a page at a time
+func (c *Controller) GetNotificationRecipients(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "email", "email")
+	if !ok {
+		return
+	}
+	notification, ok := c.findNotification(w, r)
+	if !ok {
+		return
+	}
+
+	var response RecipientsResponse
+	response.Recipients, response.NextCursor = paginateStrings(notification.Recipients, p)
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// Lists the deliveries of a notification along with their status, a page at a time
+func (c *Controller) GetNotificationDeliveries(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "created_at", "created_at")
+	if !ok {
+		return
+	}
+	notification, ok := c.findNotification(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, next, err := c.Store.ListNotificationDeliveries(notification.ID, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving deliveries")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, DeliveriesResponse{Deliveries: deliveries, NextCursor: nextCursor(p, next)})
+}
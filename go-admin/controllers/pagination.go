@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// Position of an item in a sorted list, encoded as the opaque `next_cursor` along with the sort it was issued for
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+func (c *cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded cursor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return &decoded, nil
+}
+
+// Parses the page query params: `sort` is one of the given fields (the first one by default),
+// prefixed by `-` for a descending order, `limit` defaults to 100 and `cursor` is the `next_cursor` of the previous page.
+// Responds with an error if they are invalid.
+func parsePage(w http.ResponseWriter, r *http.Request, defaultSort string, fields ...string) (*models.Page, bool) {
+	query := r.URL.Query()
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = defaultSort
+	}
+	p := &models.Page{Limit: defaultPageLimit}
+	p.Sort, p.Desc = strings.TrimPrefix(sortBy, "-"), strings.HasPrefix(sortBy, "-")
+	if !containsField(fields, p.Sort) {
+		invalidField(w, "sort", "oneof", "Invalid sort, expected one of "+strings.Join(fields, ", "))
+		return nil, false
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			invalidField(w, "limit", "max", fmt.Sprintf("Invalid limit, expected 1 to %d", maxPageLimit))
+			return nil, false
+		}
+		p.Limit = limit
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		after, err := decodeCursor(value)
+		// A cursor only makes sense with the sort it was issued for
+		if err != nil || after.Sort != sortBy {
+			invalidField(w, "cursor", "cursor", "Invalid cursor")
+			return nil, false
+		}
+		p.After = &models.Cursor{Value: after.Value, Key: after.Key}
+	}
+	return p, true
+}
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Encodes the cursor of the next page returned by a list, empty if this is the last page
+func nextCursor(p *models.Page, next *models.Cursor) string {
+	if next == nil {
+		return ""
+	}
+	sortBy := p.Sort
+	if p.Desc {
+		sortBy = "-" + sortBy
+	}
+	return (&cursor{Sort: sortBy, Value: next.Value, Key: next.Key}).encode()
+}
+
+// Pages the emails (or codes) of the lists computed from a single record or from a few relations (e.g. the common
+// students), sorted by the values themselves. The lists of records are paged by the Store.
+func paginateStrings(values []string, p *models.Page) ([]string, string) {
+	identity := func(value string) string { return value }
+	page, next := models.Paginate(values, p, func(value string, field string) string { return value }, identity)
+	return page, nextCursor(p, next)
+}
+
+// Responds with an error to a failed list of records, detail describes the failure (e.g. "Error retrieving students")
+func listFailed(w http.ResponseWriter, err error, detail string) {
+	if errors.Is(err, models.ErrInvalidCursor) {
+		invalidField(w, "cursor", "cursor", "Invalid cursor")
+		return
+	}
+	log.Println(err)
+	utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, detail)
+}
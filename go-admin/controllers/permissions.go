@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/bensohh/go-admin/auth"
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+)
+
+// Permission required by a route, see Router
+type permission string
+
+const (
+	// Read the teachers, students, classes and terms
+	readDirectory permission = "directory:read"
+	// Create, update and delete the teachers, students, classes and terms
+	writeDirectory permission = "directory:write"
+	// Change the role of the teachers
+	manageRoles permission = "roles:manage"
+	// Issue password reset tokens for the teachers, which lets the holder log in as them
+	resetPasswords permission = "passwords:reset"
+	// Read the notifications, their deliveries and the suspension histories
+	readHistory permission = "history:read"
+	// Read and export the audit log
+	readAudit permission = "audit:read"
+	// Register students with a teacher or enroll them in a class
+	register permission = "registry:write"
+	// Retrieve the recipients of and send notifications
+	notify permission = "notifications:write"
+	// Suspend and unsuspend students
+	suspend permission = "suspensions:write"
+	// List and retry the background jobs
+	manageJobs permission = "jobs:manage"
+	// Create, list and revoke their own API keys
+	manageOwnAPIKeys permission = "apikeys:manage"
+	// List and revoke their own sessions, log out
+	manageOwnSessions permission = "sessions:manage"
+	// Enroll in and pass the two-factor authentication
+	manageOwnTwoFactor permission = "twofactor:manage"
+)
+
+// Permissions of each role. Teachers only register and notify as themselves, which the handlers check.
+var rolePermissions = map[string][]permission{
+	models.RoleAdmin: {
+		readDirectory, writeDirectory, manageRoles, resetPasswords, readHistory, readAudit, register, notify, suspend, manageJobs,
+		manageOwnAPIKeys, manageOwnSessions, manageOwnTwoFactor,
+	},
+	models.RoleTeacher: {readDirectory, readHistory, register, notify, manageOwnAPIKeys, manageOwnSessions, manageOwnTwoFactor},
+	models.RoleAuditor: {readDirectory, readHistory, readAudit, manageOwnAPIKeys, manageOwnSessions, manageOwnTwoFactor},
+}
+
+func allowed(role string, p permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// The permissions only the admins have require the caller to have passed the two-factor authentication
+func requiresTwoFactor(p permission) bool {
+	for role := range rolePermissions {
+		if role != models.RoleAdmin && allowed(role, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Responds with 403 unless the role of the caller has the permission (and passed the two-factor authentication
+// if required), runs the handler otherwise
+func (c *Controller) authorize(p permission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.FromContext(r.Context())
+		if principal == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, utils.CodeUnauthenticated, "Unauthorized")
+			return
+		}
+		if !allowed(principal.Role, p) {
+			utils.RespondWithError(w, http.StatusForbidden, codeInsufficientRole,
+				"Forbidden, the "+principal.Role+" role does not have the "+string(p)+" permission")
+			return
+		}
+		if requiresTwoFactor(p) && !principal.TwoFactor {
+			utils.RespondWithError(w, http.StatusForbidden, codeTwoFactorRequired,
+				"Forbidden, the "+string(p)+" permission requires a session which passed the two-factor authentication")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Whether the caller is an admin, who may act as any teacher
+func isAdmin(r *http.Request) bool {
+	principal := auth.FromContext(r.Context())
+	return principal != nil && principal.Role == models.RoleAdmin
+}
+
+// Whether the caller only reads the notifications they sent, the teachers do while the admins and auditors read them all
+func readsOwnHistoryOnly(r *http.Request) bool {
+	principal := auth.FromContext(r.Context())
+	return principal != nil && principal.Role == models.RoleTeacher
+}
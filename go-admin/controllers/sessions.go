@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bensohh/go-admin/auth"
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+	"github.com/gorilla/mux"
+)
+
+// Not validated, a missing email or password is wrong credentials (401)
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshSessionRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type SessionResponse struct {
+	// Sent as `Authorization: Bearer <access token>`
+	AccessToken string `json:"access_token"`
+	// Single use, exchanged for new tokens with /api/auth/refresh
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// Seconds until the access token expires
+	ExpiresIn int `json:"expires_in"`
+	// The teacher enabled two-factor authentication, the session has to pass it with /api/auth/2fa/verify
+	// before using the admin routes
+	TwoFactorRequired bool           `json:"two_factor_required"`
+	Session           models.Session `json:"session"`
+}
+
+type SessionsResponse struct {
+	Sessions   []models.Session `json:"sessions"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type CreatePasswordResetResponse struct {
+	// Only returned when the reset is created, only its hash is stored
+	Token         string               `json:"token"`
+	PasswordReset models.PasswordReset `json:"password_reset"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func sessionResponse(tokens *auth.SessionTokens) SessionResponse {
+	return SessionResponse{
+		AccessToken:       tokens.AccessToken,
+		RefreshToken:      tokens.RefreshToken,
+		TokenType:         "Bearer",
+		ExpiresIn:         int(time.Until(tokens.Session.AccessExpiresAt).Seconds()),
+		TwoFactorRequired: tokens.TwoFactorRequired,
+		Session:           *tokens.Session,
+	}
+}
+
+// Starts a session for the teacher whose email and password match
+func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
+	var bodyParams LoginRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	email, err := utils.NormalizeEmail(bodyParams.Email)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, codeInvalidCredentials, "Invalid email or password")
+		return
+	}
+
+	tokens, err := auth.Login(c.Store, email, bodyParams.Password)
+
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		utils.RespondWithError(w, http.StatusUnauthorized, codeInvalidCredentials, "Invalid email or password")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error creating session")
+		return
+	}
+
+	c.auditAs(r, email, "auth.login", tokens.Session.ID, nil, tokens.Session)
+	utils.RespondWithJSON(w, http.StatusCreated, sessionResponse(tokens))
+}
+
+// Exchanges a refresh token for new access and refresh tokens
+func (c *Controller) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	var bodyParams RefreshSessionRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	tokens, err := auth.Refresh(c.Store, bodyParams.RefreshToken)
+
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		utils.RespondWithError(w, http.StatusUnauthorized, codeInvalidRefreshToken, "Unauthorized, invalid or expired refresh token")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error refreshing session")
+		return
+	}
+
+	c.auditAs(r, tokens.Session.TeacherEmail, "auth.refresh", tokens.Session.ID, nil, tokens.Session)
+	utils.RespondWithJSON(w, http.StatusOK, sessionResponse(tokens))
+}
+
+// Revokes the session the request is authenticated with
+func (c *Controller) Logout(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil || principal.Method != auth.MethodSession {
+		utils.RespondWithError(w, http.StatusBadRequest, codeSessionRequired, "Not authenticated with a session")
+		return
+	}
+
+	err := c.Store.RevokeSession(principal.TeacherEmail, principal.SessionID, time.Now())
+
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error revoking session")
+		return
+	}
+
+	c.audit(r, "auth.logout", principal.SessionID, nil, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the sessions of the authenticated teacher (revoked and expired ones included), a page at a time
+func (c *Controller) ListSessions(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "created_at", "created_at")
+	if !ok {
+		return
+	}
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	sessions, next, err := c.Store.ListSessions(teacher.Email, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving sessions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, SessionsResponse{Sessions: sessions, NextCursor: nextCursor(p, next)})
+}
+
+// Revokes one of the sessions of the authenticated teacher, e.g. on a lost device
+func (c *Controller) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	err := c.Store.RevokeSession(teacher.Email, mux.Vars(r)["id"], time.Now())
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeSessionNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error revoking session")
+		return
+	}
+
+	c.audit(r, "sessions.revoke", mux.Vars(r)["id"], nil, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Creates a password reset token for a teacher, handed over to them to set their password
+func (c *Controller) CreatePasswordReset(w http.ResponseWriter, r *http.Request) {
+	email, ok := teacherEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	token, reset, err := auth.IssuePasswordReset(c.Store, email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTeacherNotFound, "Teacher not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error creating password reset")
+		return
+	}
+
+	c.audit(r, "teachers.password_reset", reset.TeacherEmail, nil, reset)
+	utils.RespondWithJSON(w, http.StatusCreated, CreatePasswordResetResponse{Token: token, PasswordReset: *reset})
+}
+
+// Sets the password of the teacher of a password reset token, their sessions are revoked
+func (c *Controller) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var bodyParams ResetPasswordRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	reset, err := auth.ResetPassword(c.Store, bodyParams.Token, bodyParams.Password)
+
+	if errors.Is(err, auth.ErrInvalidPassword) {
+		invalidField(w, "password", "len", "Invalid password, "+err.Error())
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidResetToken) {
+		utils.RespondWithError(w, http.StatusBadRequest, codeInvalidResetToken, "Invalid or expired token")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error resetting password")
+		return
+	}
+
+	// The caller is not authenticated, the token tells who they are
+	c.auditAs(r, reset.TeacherEmail, "auth.password_reset", reset.TeacherEmail, nil, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,17 +3,136 @@ package controllers
 import (
 	"net/http"
 
+	"github.com/bensohh/go-admin/auth"
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/notifications"
+	"github.com/bensohh/go-admin/utils"
 	"github.com/gorilla/mux"
 )
 
-func New() http.Handler {
+// Controller holds the dependencies shared by the handlers
+type Controller struct {
+	Store models.Store
+	// Rules picking the recipients of the notifications
+	Resolver *notifications.RecipientResolver
+	// Authenticates the callers of the /api routes
+	Auth *auth.Authenticator
+}
+
+// Controller using the default notification rules, only accepting API keys (no JWT secret)
+func NewController(store models.Store) *Controller {
+	return &Controller{
+		Store:    store,
+		Resolver: notifications.DefaultResolver(store),
+		Auth:     auth.NewAuthenticator(store, nil),
+	}
+}
+
+// Identifies who is calling the API, recorded in the histories
+func actor(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		return principal.TeacherEmail
+	}
+	return "anonymous"
+}
+
+// Routes the API to a controller using the default notification rules, the callers are authenticated by the authenticator
+func New(store models.Store, authenticator *auth.Authenticator) http.Handler {
+	c := NewController(store)
+	c.Auth = authenticator
+	return Router(c)
+}
+
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithError(w, http.StatusNotFound, codeRouteNotFound, "No route matches "+r.URL.Path)
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
+
+// Routes the API to the controller's handlers, every /api route requires an authenticated caller
+// whose role has the permission of the route (see rolePermissions)
+func Router(c *Controller) http.Handler {
 	router := mux.NewRouter()
+	router.Use(utils.RequestIDMiddleware)
+	// The middlewares only run for the matched routes
+	router.NotFoundHandler = utils.RequestIDMiddleware(http.HandlerFunc(routeNotFound))
+	router.MethodNotAllowedHandler = utils.RequestIDMiddleware(http.HandlerFunc(methodNotAllowed))
 
 	router.HandleFunc("/", TestServer).Methods("GET")
-	router.HandleFunc("/api/register", RegisterStudents).Methods("POST")
-	router.HandleFunc("/api/commonstudents", GetCommonStudents).Methods("GET")
-	router.HandleFunc("/api/suspend", SuspendStudent).Methods("POST")
-	router.HandleFunc("/api/retrievefornotifications", GetStudentsWithNotification).Methods("POST")
+
+	// Routes for the callers who are not authenticated yet
+	router.HandleFunc("/api/auth/login", c.Login).Methods("POST")
+	router.HandleFunc("/api/auth/refresh", c.RefreshSession).Methods("POST")
+	router.HandleFunc("/api/auth/password-reset", c.ResetPassword).Methods("POST")
+
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(c.Auth.Middleware)
+	api.HandleFunc("/register", c.authorize(register, c.RegisterStudents)).Methods("POST")
+	api.HandleFunc("/deregister", c.authorize(register, c.DeregisterStudents)).Methods("POST")
+	api.HandleFunc("/commonstudents", c.authorize(readDirectory, c.GetCommonStudents)).Methods("GET")
+	api.HandleFunc("/suspend", c.authorize(suspend, c.SuspendStudent)).Methods("POST")
+	api.HandleFunc("/unsuspend", c.authorize(suspend, c.UnSuspendStudent)).Methods("POST")
+	api.HandleFunc("/retrievefornotifications", c.authorize(notify, c.GetStudentsWithNotification)).Methods("POST")
+	api.HandleFunc("/notifications", c.authorize(readHistory, c.ListNotifications)).Methods("GET")
+	api.HandleFunc("/notifications", c.authorize(notify, c.CreateNotification)).Methods("POST")
+	api.HandleFunc("/notifications/{id}", c.authorize(readHistory, c.GetNotification)).Methods("GET")
+	api.HandleFunc("/notifications/{id}", c.authorize(notify, c.RescheduleNotification)).Methods("PATCH")
+	api.HandleFunc("/notifications/{id}/cancel", c.authorize(notify, c.CancelNotification)).Methods("POST")
+	api.HandleFunc("/notifications/{id}/recipients", c.authorize(readHistory, c.GetNotificationRecipients)).Methods("GET")
+	api.HandleFunc("/notifications/{id}/deliveries", c.authorize(readHistory, c.GetNotificationDeliveries)).Methods("GET")
+
+	api.HandleFunc("/teachers", c.authorize(readDirectory, c.ListTeachers)).Methods("GET")
+	api.HandleFunc("/teachers", c.authorize(writeDirectory, c.CreateTeacher)).Methods("POST")
+	api.HandleFunc("/teachers/{email}", c.authorize(readDirectory, c.GetTeacher)).Methods("GET")
+	api.HandleFunc("/teachers/{email}", c.authorize(writeDirectory, c.UpdateTeacher)).Methods("PUT", "PATCH")
+	api.HandleFunc("/teachers/{email}", c.authorize(writeDirectory, c.DeleteTeacher)).Methods("DELETE")
+	api.HandleFunc("/teachers/{email}/role", c.authorize(manageRoles, c.UpdateTeacherRole)).Methods("PUT")
+	api.HandleFunc("/teachers/{email}/password-reset", c.authorize(resetPasswords, c.CreatePasswordReset)).Methods("POST")
+	api.HandleFunc("/teachers/{email}/students", c.authorize(readDirectory, c.GetTeacherStudents)).Methods("GET")
+
+	api.HandleFunc("/students", c.authorize(readDirectory, c.ListStudents)).Methods("GET")
+	api.HandleFunc("/students", c.authorize(writeDirectory, c.CreateStudent)).Methods("POST")
+	api.HandleFunc("/students/{email}", c.authorize(readDirectory, c.GetStudent)).Methods("GET")
+	api.HandleFunc("/students/{email}", c.authorize(writeDirectory, c.UpdateStudent)).Methods("PUT", "PATCH")
+	api.HandleFunc("/students/{email}", c.authorize(writeDirectory, c.DeleteStudent)).Methods("DELETE")
+	api.HandleFunc("/students/{email}/teachers", c.authorize(readDirectory, c.GetStudentTeachers)).Methods("GET")
+	api.HandleFunc("/students/{email}/suspensions", c.authorize(readHistory, c.GetStudentSuspensions)).Methods("GET")
+	api.HandleFunc("/students/{email}/classes", c.authorize(readDirectory, c.GetStudentClasses)).Methods("GET")
+
+	api.HandleFunc("/terms", c.authorize(readDirectory, c.ListTerms)).Methods("GET")
+	api.HandleFunc("/terms", c.authorize(writeDirectory, c.CreateTerm)).Methods("POST")
+	api.HandleFunc("/terms/current", c.authorize(readDirectory, c.GetCurrentTerm)).Methods("GET")
+	api.HandleFunc("/terms/{name}", c.authorize(readDirectory, c.GetTerm)).Methods("GET")
+	api.HandleFunc("/terms/{name}/rollover", c.authorize(writeDirectory, c.RolloverTerm)).Methods("POST")
+
+	api.HandleFunc("/classes", c.authorize(readDirectory, c.ListClasses)).Methods("GET")
+	api.HandleFunc("/classes", c.authorize(writeDirectory, c.CreateClass)).Methods("POST")
+	api.HandleFunc("/classes/{code}", c.authorize(readDirectory, c.GetClass)).Methods("GET")
+	api.HandleFunc("/classes/{code}", c.authorize(writeDirectory, c.UpdateClass)).Methods("PUT", "PATCH")
+	api.HandleFunc("/classes/{code}", c.authorize(writeDirectory, c.DeleteClass)).Methods("DELETE")
+	api.HandleFunc("/classes/{code}/students", c.authorize(readDirectory, c.GetClassStudents)).Methods("GET")
+	api.HandleFunc("/classes/{code}/students", c.authorize(register, c.EnrollStudents)).Methods("POST")
+	api.HandleFunc("/classes/{code}/students/{email}", c.authorize(register, c.UnenrollStudent)).Methods("DELETE")
+
+	api.HandleFunc("/auth/logout", c.authorize(manageOwnSessions, c.Logout)).Methods("POST")
+	api.HandleFunc("/auth/sessions", c.authorize(manageOwnSessions, c.ListSessions)).Methods("GET")
+	api.HandleFunc("/auth/sessions/{id}", c.authorize(manageOwnSessions, c.RevokeSession)).Methods("DELETE")
+	api.HandleFunc("/auth/2fa", c.authorize(manageOwnTwoFactor, c.GetTwoFactor)).Methods("GET")
+	api.HandleFunc("/auth/2fa/enroll", c.authorize(manageOwnTwoFactor, c.EnrollTwoFactor)).Methods("POST")
+	api.HandleFunc("/auth/2fa/confirm", c.authorize(manageOwnTwoFactor, c.ConfirmTwoFactor)).Methods("POST")
+	api.HandleFunc("/auth/2fa/verify", c.authorize(manageOwnTwoFactor, c.VerifyTwoFactor)).Methods("POST")
+
+	api.HandleFunc("/apikeys", c.authorize(manageOwnAPIKeys, c.ListAPIKeys)).Methods("GET")
+	api.HandleFunc("/apikeys", c.authorize(manageOwnAPIKeys, c.CreateAPIKey)).Methods("POST")
+	api.HandleFunc("/apikeys/{id}", c.authorize(manageOwnAPIKeys, c.RevokeAPIKey)).Methods("DELETE")
+
+	api.HandleFunc("/audit", c.authorize(readAudit, c.ListAuditEntries)).Methods("GET")
+	api.HandleFunc("/audit/export", c.authorize(readAudit, c.ExportAuditEntries)).Methods("GET")
+
+	api.HandleFunc("/admin/jobs", c.authorize(manageJobs, c.ListJobs)).Methods("GET")
+	api.HandleFunc("/admin/jobs/{id}/retry", c.authorize(manageJobs, c.RetryJob)).Methods("POST")
 
 	return router
 }
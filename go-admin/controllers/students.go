@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+)
+
+type CreateStudentRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	Name   string `json:"name" validate:"max=255"`
+	Cohort string `json:"cohort" validate:"group_name"`
+}
+
+// Only the fields which are set are updated
+type UpdateStudentRequest struct {
+	Name   *string `json:"name" validate:"omitempty,max=255"`
+	Cohort *string `json:"cohort" validate:"omitempty,group_name"`
+}
+
+type StudentsResponse struct {
+	Students   []models.Student `json:"students"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type StudentTeachersResponse struct {
+	Teachers   []string `json:"teachers"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type StudentSuspensionsResponse struct {
+	Suspended   bool                     `json:"suspended"`
+	Current     *models.Suspension       `json:"current"`
+	Suspensions []models.Suspension      `json:"suspensions"`
+	Events      []models.SuspensionEvent `json:"events"`
+}
+
+// Creates a new student
+func (c *Controller) CreateStudent(w http.ResponseWriter, r *http.Request) {
+	var bodyParams CreateStudentRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	student := models.Student{Email: bodyParams.Email, Name: bodyParams.Name, Cohort: strings.TrimSpace(bodyParams.Cohort)}
+	err := c.Store.CreateStudent(&student)
+
+	if errors.Is(err, models.ErrDuplicate) {
+		utils.RespondWithError(w, http.StatusConflict, codeStudentExists, "Student already exists")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error creating student")
+		return
+	}
+
+	c.audit(r, "students.create", student.Email, nil, student)
+	utils.RespondWithJSON(w, http.StatusCreated, student)
+}
+
+// Lists the students a page at a time, optionally filtered by the `name_prefix` and `suspended` query params
+func (c *Controller) ListStudents(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "email", "email", "name", "created_at")
+	if !ok {
+		return
+	}
+	suspended, ok := parseSuspended(w, r)
+	if !ok {
+		return
+	}
+
+	students, next, err := c.Store.ListStudents(models.StudentFilter{
+		NamePrefix:  r.URL.Query().Get("name_prefix"),
+		Suspended:   suspended,
+		SuspendedAt: time.Now(),
+	}, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving students")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, StudentsResponse{Students: students, NextCursor: nextCursor(p, next)})
+}
+
+// Parses the `suspended` query param (true or false), nil if not set. Responds with an error if it is invalid.
+func parseSuspended(w http.ResponseWriter, r *http.Request) (*bool, bool) {
+	value := r.URL.Query().Get("suspended")
+	if value == "" {
+		return nil, true
+	}
+	wanted, err := strconv.ParseBool(value)
+	if err != nil {
+		invalidField(w, "suspended", "boolean", "Invalid suspended, expected true or false")
+		return nil, false
+	}
+	return &wanted, true
+}
+
+// Returns whether a student is kept by the `suspended` query param (true or false), every student is kept if not set.
+// Responds with an error if the param is invalid.
+func (c *Controller) suspendedFilter(w http.ResponseWriter, r *http.Request) (func(email string) bool, bool) {
+	wanted, ok := parseSuspended(w, r)
+	if !ok {
+		return nil, false
+	}
+	if wanted == nil {
+		return func(string) bool { return true }, true
+	}
+
+	students, err := c.Store.ListSuspendedStudents(time.Now())
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving suspended students")
+		return nil, false
+	}
+	suspended := make(map[string]bool)
+	for _, student := range students {
+		suspended[student] = true
+	}
+	return func(email string) bool { return suspended[email] == *wanted }, true
+}
+
+// Keeps the students matching the `suspended` query param, see suspendedFilter
+func (c *Controller) filterSuspended(w http.ResponseWriter, r *http.Request, students []string) ([]string, bool) {
+	keep, ok := c.suspendedFilter(w, r)
+	if !ok {
+		return nil, false
+	}
+	filtered := []string{}
+	for _, student := range students {
+		if keep(student) {
+			filtered = append(filtered, student)
+		}
+	}
+	return filtered, true
+}
+
+// Gets a student by email
+func (c *Controller) GetStudent(w http.ResponseWriter, r *http.Request) {
+	email, ok := studentEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	student, err := c.Store.GetStudent(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving student")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, student)
+}
+
+// Updates a student's name and cohort
+func (c *Controller) UpdateStudent(w http.ResponseWriter, r *http.Request) {
+	email, ok := studentEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	var bodyParams UpdateStudentRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	changes := models.StudentChanges{Name: bodyParams.Name}
+	if bodyParams.Cohort != nil {
+		// An empty cohort clears it
+		cohort := strings.TrimSpace(*bodyParams.Cohort)
+		changes.Cohort = &cohort
+	}
+
+	before, _ := c.Store.GetStudent(email)
+	student, err := c.Store.UpdateStudent(email, changes)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating student")
+		return
+	}
+
+	c.audit(r, "students.update", student.Email, before, student)
+	utils.RespondWithJSON(w, http.StatusOK, student)
+}
+
+// Deletes a student, their enrollments are deleted as well
+func (c *Controller) DeleteStudent(w http.ResponseWriter, r *http.Request) {
+	email, ok := studentEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	before, _ := c.Store.GetStudent(email)
+	err := c.Store.DeleteStudent(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	if errors.Is(err, models.ErrReferenced) {
+		utils.RespondWithError(w, http.StatusConflict, codeStudentHasSuspensions, "Student has a suspension history, which is kept")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error deleting student")
+		return
+	}
+
+	c.audit(r, "students.delete", email, before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the teachers a student is registered under during the `term` query param (the current term by default),
+// a page at a time
+func (c *Controller) GetStudentTeachers(w http.ResponseWriter, r *http.Request) {
+	email, ok := studentEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	p, ok := parsePage(w, r, "email", "email")
+	if !ok {
+		return
+	}
+
+	student, err := c.Store.GetStudent(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving student")
+		return
+	}
+
+	term, ok := c.findTerm(w, r.URL.Query().Get("term"))
+	if !ok {
+		return
+	}
+
+	teachers, err := c.Store.GetStudentTeachers(term.ID, student.Email)
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving registered teachers")
+		return
+	}
+
+	var response StudentTeachersResponse
+	response.Teachers, response.NextCursor = paginateStrings(teachers, p)
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// Returns a student's suspension history along with the state derived from it
+func (c *Controller) GetStudentSuspensions(w http.ResponseWriter, r *http.Request) {
+	email, ok := studentEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	student, err := c.Store.GetStudent(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving student")
+		return
+	}
+
+	events, err := c.Store.ListSuspensionEvents(student.Email)
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving suspension history")
+		return
+	}
+
+	suspensions := models.DeriveSuspensions(events)
+	current := models.ActiveSuspension(suspensions, time.Now())
+
+	utils.RespondWithJSON(w, http.StatusOK, StudentSuspensionsResponse{
+		Suspended:   current != nil,
+		Current:     current,
+		Suspensions: suspensions,
+		Events:      events,
+	})
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+)
+
+type CreateTeacherRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name" validate:"max=255"`
+	// admin, teacher or auditor, teacher by default
+	Role string `json:"role" validate:"omitempty,oneof=admin teacher auditor"`
+}
+
+type UpdateTeacherRequest struct {
+	Name string `json:"name" validate:"max=255"`
+}
+
+type UpdateTeacherRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin teacher auditor"`
+}
+
+type TeachersResponse struct {
+	Teachers   []models.Teacher `json:"teachers"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type TeacherStudentsResponse struct {
+	Students   []string `json:"students"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// Creates a new teacher
+func (c *Controller) CreateTeacher(w http.ResponseWriter, r *http.Request) {
+	var bodyParams CreateTeacherRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	teacher := models.Teacher{Email: bodyParams.Email, Name: bodyParams.Name, Role: bodyParams.Role}
+	err := c.Store.CreateTeacher(&teacher)
+
+	if errors.Is(err, models.ErrDuplicate) {
+		utils.RespondWithError(w, http.StatusConflict, codeTeacherExists, "Teacher already exists")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error creating teacher")
+		return
+	}
+
+	c.audit(r, "teachers.create", teacher.Email, nil, teacher)
+	utils.RespondWithJSON(w, http.StatusCreated, teacher)
+}
+
+// Lists the teachers whose name starts with the `name_prefix` query param, a page at a time
+func (c *Controller) ListTeachers(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "email", "email", "name", "created_at")
+	if !ok {
+		return
+	}
+
+	teachers, next, err := c.Store.ListTeachers(models.TeacherFilter{NamePrefix: r.URL.Query().Get("name_prefix")}, p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving teachers")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, TeachersResponse{Teachers: teachers, NextCursor: nextCursor(p, next)})
+}
+
+// Gets a teacher by email
+func (c *Controller) GetTeacher(w http.ResponseWriter, r *http.Request) {
+	email, ok := teacherEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	teacher, err := c.Store.GetTeacher(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTeacherNotFound, "Teacher not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving teacher")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, teacher)
+}
+
+// Updates a teacher's name
+func (c *Controller) UpdateTeacher(w http.ResponseWriter, r *http.Request) {
+	email, ok := teacherEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	var bodyParams UpdateTeacherRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	before, _ := c.Store.GetTeacher(email)
+	teacher, err := c.Store.UpdateTeacherName(email, bodyParams.Name)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTeacherNotFound, "Teacher not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating teacher")
+		return
+	}
+
+	c.audit(r, "teachers.update", teacher.Email, before, teacher)
+	utils.RespondWithJSON(w, http.StatusOK, teacher)
+}
+
+// Deletes a teacher, their own class (teacher-level registrations) is deleted as well
+func (c *Controller) DeleteTeacher(w http.ResponseWriter, r *http.Request) {
+	email, ok := teacherEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	before, _ := c.Store.GetTeacher(email)
+	err := c.Store.DeleteTeacher(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTeacherNotFound, "Teacher not found")
+		return
+	}
+	if errors.Is(err, models.ErrReferenced) {
+		utils.RespondWithError(w, http.StatusConflict, codeTeacherHasNotifications, "Teacher has sent notifications, which are kept")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error deleting teacher")
+		return
+	}
+
+	c.audit(r, "teachers.delete", email, before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the students registered under a teacher during the `term` query param (the current term by default),
+// a page at a time, optionally filtered by the `suspended` query param
+func (c *Controller) GetTeacherStudents(w http.ResponseWriter, r *http.Request) {
+	email, ok := teacherEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	p, ok := parsePage(w, r, "email", "email")
+	if !ok {
+		return
+	}
+
+	teacher, err := c.Store.GetTeacher(email)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTeacherNotFound, "Teacher not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving teacher")
+		return
+	}
+
+	term, ok := c.findTerm(w, r.URL.Query().Get("term"))
+	if !ok {
+		return
+	}
+
+	students, err := c.Store.GetRegisteredStudents(term.ID, teacher.Email)
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving registered students")
+		return
+	}
+	if students, ok = c.filterSuspended(w, r, students); !ok {
+		return
+	}
+
+	var response TeacherStudentsResponse
+	response.Students, response.NextCursor = paginateStrings(students, p)
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// Changes the role of a teacher, which applies to their next requests
+func (c *Controller) UpdateTeacherRole(w http.ResponseWriter, r *http.Request) {
+	email, ok := teacherEmailVar(w, r)
+	if !ok {
+		return
+	}
+
+	var bodyParams UpdateTeacherRoleRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	before, _ := c.Store.GetTeacher(email)
+	teacher, err := c.Store.UpdateTeacherRole(email, bodyParams.Role)
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTeacherNotFound, "Teacher not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating teacher")
+		return
+	}
+
+	c.audit(r, "teachers.role", teacher.Email, before, teacher)
+	utils.RespondWithJSON(w, http.StatusOK, teacher)
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/utils"
+	"github.com/gorilla/mux"
+)
+
+const maxTermNameLength = 64
+
+type CreateTermRequest struct {
+	Name     string     `json:"name" validate:"required,max=64"`
+	StartsOn *time.Time `json:"starts_on,omitempty"`
+	EndsOn   *time.Time `json:"ends_on,omitempty"`
+}
+
+type RolloverTermRequest struct {
+	// Term created as the new current term
+	Next CreateTermRequest `json:"next"`
+	// Codes of the classes whose enrollments are copied into the new term
+	// (a teacher's email carries forward the teacher-level registrations)
+	CarryForward []string `json:"carry_forward" validate:"max=1000"`
+}
+
+type TermsResponse struct {
+	Terms      []models.Term `json:"terms"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type RolloverTermResponse struct {
+	Archived models.Term `json:"archived"`
+	Current  models.Term `json:"current"`
+}
+
+// Retrieves the term with the given name, or the current term if the name is empty.
+// Responds with an error if the term cannot be found.
+func (c *Controller) findTerm(w http.ResponseWriter, name string) (*models.Term, bool) {
+	var term *models.Term
+	var err error
+	if name == "" {
+		term, err = c.Store.GetCurrentTerm()
+	} else {
+		term, err = c.Store.GetTerm(name)
+	}
+
+	if errors.Is(err, models.ErrNotFound) && name == "" {
+		utils.RespondWithError(w, http.StatusConflict, codeNoCurrentTerm, "There is no current term")
+		return nil, false
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusBadRequest, codeTermNotFound, "Invalid term")
+		return nil, false
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving term")
+		return nil, false
+	}
+	return term, true
+}
+
+// Same as findTerm, also responds with an error if the term is archived (archived terms are read only)
+func (c *Controller) findOpenTerm(w http.ResponseWriter, name string) (*models.Term, bool) {
+	term, ok := c.findTerm(w, name)
+	if ok && term.Archived() {
+		utils.RespondWithError(w, http.StatusConflict, codeTermArchived, "Term is archived")
+		return nil, false
+	}
+	return term, ok
+}
+
+// Builds a term from the request, responds with an error if it is invalid
+func newTerm(w http.ResponseWriter, request CreateTermRequest) (*models.Term, bool) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxTermNameLength {
+		invalidField(w, "name", "max", "Invalid term name")
+		return nil, false
+	}
+	if request.StartsOn != nil && request.EndsOn != nil && !request.EndsOn.After(*request.StartsOn) {
+		invalidField(w, "ends_on", "gtfield", "ends_on must be after starts_on")
+		return nil, false
+	}
+	return &models.Term{Name: name, StartsOn: request.StartsOn, EndsOn: request.EndsOn}, true
+}
+
+// Creates a new term, it becomes the current term if there is none
+func (c *Controller) CreateTerm(w http.ResponseWriter, r *http.Request) {
+	var bodyParams CreateTermRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	term, ok := newTerm(w, bodyParams)
+	if !ok {
+		return
+	}
+
+	_, err := c.Store.GetCurrentTerm()
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving term")
+		return
+	}
+	term.Current = errors.Is(err, models.ErrNotFound)
+
+	err = c.Store.CreateTerm(term)
+
+	if errors.Is(err, models.ErrDuplicate) {
+		utils.RespondWithError(w, http.StatusConflict, codeTermExists, "Term already exists")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error creating term")
+		return
+	}
+
+	c.audit(r, "terms.create", term.Name, nil, term)
+	utils.RespondWithJSON(w, http.StatusCreated, term)
+}
+
+// Lists the terms a page at a time, oldest first by default
+func (c *Controller) ListTerms(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePage(w, r, "created_at", "created_at", "name")
+	if !ok {
+		return
+	}
+
+	terms, next, err := c.Store.ListTerms(p)
+
+	if err != nil {
+		listFailed(w, err, "Error retrieving terms")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, TermsResponse{Terms: terms, NextCursor: nextCursor(p, next)})
+}
+
+// Gets the current term
+func (c *Controller) GetCurrentTerm(w http.ResponseWriter, r *http.Request) {
+	term, err := c.Store.GetCurrentTerm()
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeNoCurrentTerm, "There is no current term")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving term")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, term)
+}
+
+// Gets a term by name
+func (c *Controller) GetTerm(w http.ResponseWriter, r *http.Request) {
+	term, err := c.Store.GetTerm(mux.Vars(r)["name"])
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTermNotFound, "Term not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving term")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, term)
+}
+
+// Archives the current term and starts the next one, carrying forward the enrollments of the selected classes
+func (c *Controller) RolloverTerm(w http.ResponseWriter, r *http.Request) {
+	var bodyParams RolloverTermRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	term, err := c.Store.GetTerm(mux.Vars(r)["name"])
+
+	if errors.Is(err, models.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, codeTermNotFound, "Term not found")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving term")
+		return
+	}
+	if !term.Current {
+		utils.RespondWithError(w, http.StatusConflict, codeTermNotCurrent, "Only the current term can be rolled over")
+		return
+	}
+
+	next, ok := newTerm(w, bodyParams.Next)
+	if !ok {
+		return
+	}
+	before := *term
+	for i, code := range bodyParams.CarryForward {
+		// The code of a teacher's own class is their email as stored
+		if email, err := utils.NormalizeEmail(code); err == nil {
+			code = models.TeacherClassCode(email)
+			bodyParams.CarryForward[i] = code
+		}
+		if _, err := c.Store.GetClass(code); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, codeClassNotFound, "Invalid class")
+			return
+		}
+	}
+
+	err = c.Store.RolloverTerm(term, next, bodyParams.CarryForward)
+
+	if errors.Is(err, models.ErrDuplicate) {
+		utils.RespondWithError(w, http.StatusConflict, codeTermExists, "Term already exists")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error rolling over term")
+		return
+	}
+
+	c.audit(r, "terms.rollover", term.Name, before, RolloverTermResponse{Archived: *term, Current: *next})
+	utils.RespondWithJSON(w, http.StatusOK, RolloverTermResponse{Archived: *term, Current: *next})
+}
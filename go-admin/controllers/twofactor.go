@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bensohh/go-admin/auth"
+	"github.com/bensohh/go-admin/utils"
+)
+
+type TwoFactorResponse struct {
+	Enabled bool `json:"enabled"`
+	// Whether the request passed the two-factor authentication
+	Verified          bool `json:"verified"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type EnrollTwoFactorResponse struct {
+	// Base32 secret, for the apps which cannot scan the URI
+	Secret string `json:"secret"`
+	// otpauth:// URI, usually shown as a QR code
+	URI string `json:"uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	// Code of the authenticator app, or a recovery code when verifying
+	Code string `json:"code" validate:"required,max=64"`
+}
+
+type ConfirmTwoFactorResponse struct {
+	// Only returned once, each code can be used once instead of a code of the authenticator app
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Gets the two-factor authentication status of the authenticated teacher
+func (c *Controller) GetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	left, err := c.Store.CountRecoveryCodes(teacher.Email)
+
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error retrieving recovery codes")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, TwoFactorResponse{
+		Enabled:           teacher.TOTPEnabled,
+		Verified:          auth.FromContext(r.Context()).TwoFactor,
+		RecoveryCodesLeft: left,
+	})
+}
+
+// Starts the TOTP enrollment of the authenticated teacher, enabled once confirmed with a code
+func (c *Controller) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	enrollment, err := auth.EnrollTOTP(c.Store, teacher.Email)
+
+	if errors.Is(err, auth.ErrTwoFactorEnabled) {
+		utils.RespondWithError(w, http.StatusConflict, codeTwoFactorEnabled, "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error enrolling two-factor authentication")
+		return
+	}
+
+	c.audit(r, "twofactor.enroll", teacher.Email, nil, nil)
+	utils.RespondWithJSON(w, http.StatusCreated, EnrollTwoFactorResponse{Secret: enrollment.Secret, URI: enrollment.URI})
+}
+
+// Enables the TOTP of the authenticated teacher with a code of their app, the session passes the two-factor authentication
+func (c *Controller) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var bodyParams TwoFactorCodeRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	teacher, ok := c.actingTeacher(w, r, "")
+	if !ok {
+		return
+	}
+
+	codes, err := auth.ConfirmTOTP(c.Store, teacher.Email, bodyParams.Code)
+
+	c.auditTwoFactorFailure(r, "twofactor.confirm_failed", teacher.Email, err)
+	if !c.respondTwoFactorError(w, err) || !c.passTwoFactor(w, r) {
+		return
+	}
+
+	c.audit(r, "twofactor.confirm", teacher.Email, nil, nil)
+	utils.RespondWithJSON(w, http.StatusOK, ConfirmTwoFactorResponse{RecoveryCodes: codes})
+}
+
+// Passes the two-factor authentication for the session of the request with a code of the app or a recovery code
+func (c *Controller) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var bodyParams TwoFactorCodeRequest
+	if !decodeBody(w, r, &bodyParams) {
+		return
+	}
+
+	principal := auth.FromContext(r.Context())
+	if principal == nil || principal.Method != auth.MethodSession {
+		utils.RespondWithError(w, http.StatusBadRequest, codeSessionRequired, "Not authenticated with a session")
+		return
+	}
+
+	err := auth.VerifyTwoFactor(c.Store, principal.TeacherEmail, bodyParams.Code)
+
+	c.auditTwoFactorFailure(r, "twofactor.verify_failed", principal.TeacherEmail, err)
+	if !c.respondTwoFactorError(w, err) || !c.passTwoFactor(w, r) {
+		return
+	}
+
+	c.audit(r, "twofactor.verify", principal.TeacherEmail, nil, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The reason a code was rejected as recorded in the audit log, invalid_code or locked
+type auditedTwoFactorFailure struct {
+	Reason string `json:"reason"`
+}
+
+// Records a code rejected by auth.ConfirmTOTP or auth.VerifyTwoFactor in the audit log, nothing to do for the other errors
+func (c *Controller) auditTwoFactorFailure(r *http.Request, action string, teacherEmail string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidCode):
+		c.audit(r, action, teacherEmail, nil, auditedTwoFactorFailure{Reason: "invalid_code"})
+	case errors.Is(err, auth.ErrTwoFactorLocked):
+		c.audit(r, action, teacherEmail, nil, auditedTwoFactorFailure{Reason: "locked"})
+	}
+}
+
+// Responds with an error unless err is nil
+func (c *Controller) respondTwoFactorError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrInvalidCode):
+		utils.RespondWithError(w, http.StatusBadRequest, codeInvalidTwoFactorCode, "Invalid code")
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		utils.RespondWithError(w, http.StatusConflict, codeTwoFactorEnabled, "Two-factor authentication is already enabled")
+	case errors.Is(err, auth.ErrTwoFactorNotEnabled):
+		utils.RespondWithError(w, http.StatusConflict, codeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+	case errors.Is(err, auth.ErrTwoFactorLocked):
+		utils.RespondWithError(w, http.StatusTooManyRequests, codeTwoFactorLocked, "Too many invalid codes, try again later")
+	default:
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error verifying code")
+	}
+	return false
+}
+
+// Records that the session of the request passed the two-factor authentication, nothing to do for the other requests
+func (c *Controller) passTwoFactor(w http.ResponseWriter, r *http.Request) bool {
+	principal := auth.FromContext(r.Context())
+	if principal.Method != auth.MethodSession {
+		return true
+	}
+	if err := c.Store.VerifySessionTwoFactor(principal.SessionID, time.Now()); err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error updating session")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bensohh/go-admin/mentions"
+	"github.com/bensohh/go-admin/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+// Largest request body accepted, the larger ones are rejected with 413
+const maxBodyBytes = 1 << 20
+
+// Checks the request structs against their `validate` tags
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// The field errors use the JSON names of the fields
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// Same emails as the ones stored, which are trimmed and lowercased first (see utils.NormalizeEmail)
+	v.RegisterValidation("email", func(fl validator.FieldLevel) bool {
+		_, err := utils.NormalizeEmail(fl.Field().String())
+		return err == nil
+	})
+	// Class codes and cohorts have to be mentionable, e.g. @class:3A or @cohort:2027. Empty is valid (e.g. clears
+	// the cohort), use required to reject it.
+	v.RegisterValidation("group_name", func(fl validator.FieldLevel) bool {
+		name := fl.Field().String()
+		return name == "" || mentions.ValidGroupName(strings.TrimSpace(name))
+	})
+	return v
+}
+
+// Decodes the JSON body of the request into dst (a pointer to a request struct) and checks its `validate` tags.
+// Responds with an error if the body is too large, malformed, has unknown fields or invalid fields.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	// The body is a single JSON value
+	if err == nil && decoder.Decode(&json.RawMessage{}) != io.EOF {
+		err = errors.New("unexpected data after the JSON value")
+	}
+
+	var tooLarge *http.MaxBytesError
+	var wrongType *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+	case errors.As(err, &tooLarge):
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, codeRequestTooLarge,
+			fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit))
+		return false
+	case errors.As(err, &wrongType) && wrongType.Field != "":
+		invalidField(w, wrongType.Field, "type", fmt.Sprintf("%s must be a JSON %s", wrongType.Field, jsonType(wrongType.Type)))
+		return false
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		invalidField(w, field, "unknown", "Unknown field "+field)
+		return false
+	case errors.Is(err, io.EOF):
+		utils.RespondWithError(w, http.StatusBadRequest, utils.CodeMalformedRequest, "Request body is empty")
+		return false
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, utils.CodeMalformedRequest, "Malformed JSON body, "+strings.TrimPrefix(err.Error(), "json: "))
+		return false
+	}
+
+	err = validate.Struct(dst)
+
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fieldErrors := make([]utils.FieldError, len(invalid))
+		details := make([]string, len(invalid))
+		for i, fieldError := range invalid {
+			fieldErrors[i] = newFieldError(fieldError)
+			details[i] = fieldErrors[i].Detail
+		}
+		utils.RespondWithValidationError(w, strings.Join(details, ", "), fieldErrors...)
+		return false
+	}
+	if err != nil {
+		log.Println(err)
+		utils.RespondWithError(w, http.StatusInternalServerError, utils.CodeInternalError, "Error validating request")
+		return false
+	}
+
+	normalizeEmails(dst)
+	return true
+}
+
+// Replaces the fields of the request struct validated as emails (or lists of emails) with the emails as stored,
+// see utils.NormalizeEmail. Once validated every email normalizes, the empty optional ones are left as they are.
+func normalizeEmails(dst interface{}) {
+	value := reflect.Indirect(reflect.ValueOf(dst))
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < value.NumField(); i++ {
+		if !slices.Contains(strings.Split(value.Type().Field(i).Tag.Get("validate"), ","), "email") {
+			continue
+		}
+		// An optional list is a pointer, left nil when not set
+		field := reflect.Indirect(value.Field(i))
+		switch field.Kind() {
+		case reflect.String:
+			normalizeEmail(field)
+		case reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				normalizeEmail(field.Index(j))
+			}
+		}
+	}
+}
+
+func normalizeEmail(value reflect.Value) {
+	if email, err := utils.NormalizeEmail(value.String()); err == nil {
+		value.SetString(email)
+	}
+}
+
+// Names the JSON type of a Go type, e.g. array for []string
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	default:
+		return "number"
+	}
+}
+
+// Describes a broken `validate` tag, the field is the JSON path of the value (e.g. students[2] or next.name)
+func newFieldError(fieldError validator.FieldError) utils.FieldError {
+	_, field, _ := strings.Cut(fieldError.Namespace(), ".")
+	param := fieldError.Param()
+
+	var detail string
+	switch fieldError.Tag() {
+	case "required":
+		detail = "is required"
+	case "email":
+		detail = "must be a valid email"
+	case "oneof":
+		detail = "must be one of " + strings.ReplaceAll(param, " ", ", ")
+	case "group_name":
+		detail = "must be made of letters, digits, _ and - (and not end with -)"
+	case "min", "max":
+		bound := map[string]string{"min": "at least", "max": "at most"}[fieldError.Tag()]
+		unit := "characters"
+		if kind := fieldError.Kind(); kind == reflect.Slice || kind == reflect.Array {
+			unit = "items"
+		}
+		detail = fmt.Sprintf("must have %s %s %s", bound, param, unit)
+	default:
+		detail = "is invalid"
+	}
+	return utils.FieldError{Field: field, Code: fieldError.Tag(), Detail: field + " " + detail}
+}
+
+// Returns the `email` route variable as stored (see utils.NormalizeEmail), responds with 404 and the code
+// if it is not a valid email since no such record can exist
+func emailVar(w http.ResponseWriter, r *http.Request, notFoundCode string, detail string) (string, bool) {
+	email, err := utils.NormalizeEmail(mux.Vars(r)["email"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, notFoundCode, detail)
+		return "", false
+	}
+	return email, true
+}
+
+func teacherEmailVar(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return emailVar(w, r, codeTeacherNotFound, "Teacher not found")
+}
+
+func studentEmailVar(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return emailVar(w, r, codeStudentNotFound, "Student not found")
+}
+
+func enrollmentEmailVar(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return emailVar(w, r, codeEnrollmentNotFound, "Enrollment not found")
+}
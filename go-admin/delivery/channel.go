@@ -0,0 +1,18 @@
+package delivery
+
+import "context"
+
+// Message sent to a single recipient
+type Message struct {
+	NotificationID string
+	From           string // Teacher who sent the notification
+	To             string
+	Subject        string
+	Body           string
+}
+
+// Channel delivers messages, e.g. by email or by writing them to a log
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, message Message) error
+}
@@ -0,0 +1,120 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bensohh/go-admin/jobs"
+	"github.com/bensohh/go-admin/models"
+)
+
+// Kind of the job sending a single delivery
+const SendJob = "delivery.send"
+
+type sendPayload struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// Sends the queued deliveries through a channel, retries are handled by the job queue
+type Dispatcher struct {
+	Store   models.Store
+	Channel Channel
+}
+
+func NewDispatcher(store models.Store, channel Channel) *Dispatcher {
+	return &Dispatcher{Store: store, Channel: channel}
+}
+
+// Queues one pending delivery (and the job sending it) per recipient of the notification.
+// The recipients which already have a delivery are skipped, so queuing again after a failure does not send twice.
+func Enqueue(store models.Store, notification *models.Notification) error {
+	existing, _, err := store.ListNotificationDeliveries(notification.ID, nil)
+	if err != nil {
+		return err
+	}
+	queued := make(map[string]struct{}, len(existing))
+	for _, delivery := range existing {
+		queued[delivery.StudentEmail] = struct{}{}
+	}
+
+	deliveries := []models.Delivery{}
+	for _, student := range notification.Recipients {
+		if _, ok := queued[student]; ok {
+			continue
+		}
+		deliveries = append(deliveries, models.Delivery{
+			NotificationID: notification.ID,
+			StudentEmail:   student,
+			Status:         models.DeliveryPending,
+		})
+	}
+	now := time.Now()
+	return store.CreateDeliveries(deliveries, func(delivery models.Delivery) (*models.Job, error) {
+		return jobs.New(SendJob, sendPayload{DeliveryID: delivery.ID}, now)
+	})
+}
+
+// Registers the dispatcher as the handler of the send jobs
+func (d *Dispatcher) Register(queue *jobs.Queue) {
+	queue.Register(SendJob, d.Handle)
+}
+
+// Handles a send job, the delivery is marked as failed once the job runs out of attempts.
+// A failed delivery is pending again when its dead job is retried (see jobs.Retry).
+func (d *Dispatcher) Handle(ctx context.Context, job *models.Job) error {
+	var payload sendPayload
+	if err := jobs.DecodePayload(job, &payload); err != nil {
+		return err
+	}
+
+	delivery, err := d.Store.GetDelivery(payload.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("delivery %d: %w", payload.DeliveryID, err)
+	}
+	if delivery.Status == models.DeliverySent {
+		return nil
+	}
+	delivery.Status = models.DeliveryPending
+
+	notification, err := d.Store.GetNotification(delivery.NotificationID)
+	if err != nil {
+		return fmt.Errorf("notification %s: %w", delivery.NotificationID, err)
+	}
+
+	sendErr := d.Attempt(ctx, delivery, notification)
+	if sendErr != nil && jobs.LastAttempt(job) {
+		delivery.Status = models.DeliveryFailed
+	}
+	if err := d.Store.UpdateDelivery(delivery); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// Sends a single delivery and updates its status, the caller saves it
+func (d *Dispatcher) Attempt(ctx context.Context, delivery *models.Delivery, notification *models.Notification) error {
+	delivery.Attempts++
+	delivery.Channel = d.Channel.Name()
+
+	err := d.Channel.Send(ctx, Message{
+		NotificationID: notification.ID,
+		From:           notification.TeacherEmail,
+		To:             delivery.StudentEmail,
+		Subject:        "Notification from " + notification.TeacherEmail,
+		Body:           notification.Text,
+	})
+
+	if err != nil {
+		log.Printf("Delivery %d to %s failed: %v", delivery.ID, delivery.StudentEmail, err)
+		delivery.LastError = err.Error()
+		return err
+	}
+
+	now := time.Now()
+	delivery.Status = models.DeliverySent
+	delivery.SentAt = &now
+	delivery.LastError = ""
+	return nil
+}
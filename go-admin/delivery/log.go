@@ -0,0 +1,42 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Writes every message as a JSON line, to a file or to stdout
+type LogChannel struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewLogChannel(writer io.Writer) *LogChannel {
+	return &LogChannel{writer: writer}
+}
+
+func (c *LogChannel) Name() string {
+	return "log"
+}
+
+func (c *LogChannel) Send(ctx context.Context, message Message) error {
+	line, err := json.Marshal(map[string]string{
+		"time":            time.Now().Format(time.RFC3339),
+		"notification_id": message.NotificationID,
+		"from":            message.From,
+		"to":              message.To,
+		"subject":         message.Subject,
+		"body":            message.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.writer.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,116 @@
+package delivery
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Sends the messages by email through an SMTP server
+type SMTPChannel struct {
+	Addr string // host:port of the SMTP server
+	From string // Envelope and header sender
+	Auth smtp.Auth
+}
+
+func NewSMTPChannel(addr string, from string, username string, password string) *SMTPChannel {
+	channel := &SMTPChannel{Addr: addr, From: from}
+	if username != "" {
+		host := strings.Split(addr, ":")[0]
+		channel.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return channel
+}
+
+func (c *SMTPChannel) Name() string {
+	return "smtp"
+}
+
+// Same exchange as smtp.SendMail, bounded by the context: the connection gets its deadline
+// and is interrupted when it is cancelled, so that a hung server does not block the worker
+func (c *SMTPChannel) Send(ctx context.Context, message Message) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := c.send(conn, message); err != nil {
+		// Report the timeout or cancellation rather than the i/o error it caused
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *SMTPChannel) send(conn net.Conn, message Message) error {
+	host, _, _ := net.SplitHostPort(c.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if c.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(c.Auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(c.format(message)); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Builds the RFC 5322 message
+func (c *SMTPChannel) format(message Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	if message.From != "" {
+		fmt.Fprintf(&b, "Reply-To: %s\r\n", message.From)
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "X-Notification-ID: %s\r\n", message.NotificationID)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
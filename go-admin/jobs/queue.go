@@ -0,0 +1,216 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+)
+
+// Attempts before a job is moved to the dead state
+const DefaultMaxAttempts = 5
+
+// Processes a claimed job, returning an error schedules a retry
+type Handler func(ctx context.Context, job *models.Job) error
+
+// In-process queue running the jobs stored in the jobs table
+type Queue struct {
+	Store        models.JobStore
+	PollInterval time.Duration
+	// Running jobs locked for longer than this are considered abandoned and requeued
+	StaleAfter time.Duration
+	// Deadline of the context of each attempt, shorter than StaleAfter so that a job is not requeued while it runs
+	Timeout     time.Duration
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewQueue(store models.JobStore) *Queue {
+	return &Queue{
+		Store:        store,
+		PollInterval: time.Second,
+		StaleAfter:   10 * time.Minute,
+		Timeout:      5 * time.Minute,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Hour,
+		handlers:     make(map[string]Handler),
+	}
+}
+
+// Registers the handler for a kind of job
+func (q *Queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = handler
+}
+
+// Stores a job to be run at runAt, the payload is JSON encoded
+func Enqueue(store models.JobStore, kind string, payload interface{}, runAt time.Time) (*models.Job, error) {
+	job, err := New(kind, payload, runAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CreateJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Returns a queued job to be run at runAt without storing it (e.g. to store it along with the record it is about),
+// the payload is JSON encoded
+func New(kind string, payload interface{}, runAt time.Time) (*models.Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Job{
+		Kind:        kind,
+		Payload:     string(encoded),
+		Status:      models.JobQueued,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       runAt,
+	}, nil
+}
+
+// Decodes the JSON payload of a job
+func DecodePayload(job *models.Job, payload interface{}) error {
+	return json.Unmarshal([]byte(job.Payload), payload)
+}
+
+// Returned by Retry when the job is not dead (e.g. it was already retried)
+var ErrNotDead = errors.New("only dead jobs can be retried")
+
+// Puts a dead job back in the queue with a fresh set of attempts.
+// Returns models.ErrNotFound if there is no such job, ErrNotDead if it is not dead.
+func Retry(store models.JobStore, id uint) (*models.Job, error) {
+	job, err := store.RetryJob(id, time.Now())
+	if !errors.Is(err, models.ErrNotFound) {
+		return job, err
+	}
+	if _, err := store.GetJob(id); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotDead
+}
+
+// Delay before the next attempt: BaseBackoff doubled after every attempt, up to MaxBackoff
+func (q *Queue) Backoff(attempts int) time.Duration {
+	delay := q.BaseBackoff
+	for i := 1; i < attempts && delay < q.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.MaxBackoff {
+		delay = q.MaxBackoff
+	}
+	return delay
+}
+
+// Starts `workers` goroutines processing jobs until the context is cancelled
+func (q *Queue) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	// Requeue the jobs abandoned by a worker which died
+	ticker := time.NewTicker(q.StaleAfter)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			if count, err := q.Store.RequeueStaleJobs(time.Now().Add(-q.StaleAfter)); err != nil {
+				log.Println("Error requeueing stale jobs:", err)
+			} else if count > 0 {
+				log.Printf("Requeued %d stale job(s)", count)
+			}
+		}
+	}
+}
+
+// Processes jobs, sleeping PollInterval whenever the queue is empty
+func (q *Queue) work(ctx context.Context) {
+	for {
+		processed, err := q.RunOnce(ctx)
+		if err != nil {
+			log.Println("Error running job:", err)
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+// Claims and runs a single job, returns false if there was no job to run
+func (q *Queue) RunOnce(ctx context.Context) (bool, error) {
+	job, err := q.Store.ClaimJob(time.Now())
+	if errors.Is(err, models.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	if ok {
+		attemptCtx, cancel := context.WithTimeout(ctx, q.Timeout)
+		err = q.safeRun(attemptCtx, handler, job)
+		cancel()
+	} else {
+		err = fmt.Errorf("no handler registered for %q", job.Kind)
+	}
+
+	job.LockedAt = nil
+	if err == nil {
+		job.Status = models.JobSucceeded
+		job.LastError = ""
+	} else {
+		log.Printf("Job %d (%s) attempt %d failed: %v", job.ID, job.Kind, job.Attempts, err)
+		job.LastError = err.Error()
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = models.JobDead
+		} else {
+			job.Status = models.JobQueued
+			job.RunAt = time.Now().Add(q.Backoff(job.Attempts))
+		}
+	}
+	return true, q.Store.UpdateJob(job)
+}
+
+// Runs the handler, turning a panic into an error so the job is retried
+func (q *Queue) safeRun(ctx context.Context, handler Handler, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Returns true if the job will not be retried should this attempt fail
+func LastAttempt(job *models.Job) bool {
+	return job.Attempts >= job.MaxAttempts
+}
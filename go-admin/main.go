@@ -1,28 +1,262 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/bensohh/go-admin/auth"
 	"github.com/bensohh/go-admin/controllers"
+	"github.com/bensohh/go-admin/delivery"
+	"github.com/bensohh/go-admin/jobs"
+	"github.com/bensohh/go-admin/migrations"
 	"github.com/bensohh/go-admin/models"
+	"github.com/bensohh/go-admin/notifications"
+	"github.com/bensohh/go-admin/workers"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
 	godotenv.Load()
 
-	handler := controllers.New()
-
 	fmt.Println("Connecting to Database...")
 
-	models.ConnectDatabase()
+	db := models.ConnectDatabase()
 
 	fmt.Println("Database Connected")
 
-	err := http.ListenAndServe(":3333", handler)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := migrate(db, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		case "apikey":
+			if err := apiKey(db, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		case "role":
+			if err := role(db, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		case "password":
+			if err := password(db, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown command %q, expected: migrate up|down [steps]|status, apikey create <teacher> [name], "+
+				"role <teacher> <role> or password reset-token <teacher>", os.Args[1])
+		}
+		return
+	}
+
+	serve(db)
+}
+
+// Builds the channel configured by NOTIFICATION_CHANNEL (smtp or log, defaults to log)
+func deliveryChannel() (delivery.Channel, error) {
+	switch os.Getenv("NOTIFICATION_CHANNEL") {
+	case "smtp":
+		return delivery.NewSMTPChannel(
+			os.Getenv("SMTP_ADDR"),
+			os.Getenv("SMTP_FROM"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+		), nil
+	case "log", "":
+		path := os.Getenv("NOTIFICATION_LOG_FILE")
+		if path == "" {
+			return delivery.NewLogChannel(os.Stdout), nil
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return delivery.NewLogChannel(file), nil
+	}
+	return nil, fmt.Errorf("unknown NOTIFICATION_CHANNEL %q, expected smtp or log", os.Getenv("NOTIFICATION_CHANNEL"))
+}
+
+// Builds the recipient rules configured by NOTIFICATION_SOURCES and NOTIFICATION_FILTERS
+// (defaults to the students registered under the teacher or @mentioned, minus the suspended ones)
+func notificationResolver(store models.Store) (*notifications.RecipientResolver, error) {
+	sources, filters := os.Getenv("NOTIFICATION_SOURCES"), os.Getenv("NOTIFICATION_FILTERS")
+	if sources == "" {
+		sources = "mentioned,group,registered"
+	}
+	if filters == "" {
+		filters = "unknown_student,suspended"
+	}
+	return notifications.ParseResolver(store, sources, filters)
+}
+
+// Authenticates the API callers with the API keys of the store and the JWTs signed with JWT_SECRET
+// (the JWTs are rejected if not set), their iss and aud claims are checked against JWT_ISSUER and JWT_AUDIENCE if set
+func authenticator(store models.Store) *auth.Authenticator {
+	authenticator := auth.NewAuthenticator(store, []byte(os.Getenv("JWT_SECRET")))
+	authenticator.Issuer = os.Getenv("JWT_ISSUER")
+	authenticator.Audience = os.Getenv("JWT_AUDIENCE")
+	return authenticator
+}
+
+// Number of goroutines running jobs, configured by JOB_WORKERS (defaults to 4)
+func jobWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if err != nil || workers < 1 {
+		return 4
+	}
+	return workers
+}
+
+func serve(db *gorm.DB) {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if pending, err := migrator.Pending(); err != nil {
+		log.Println("Unable to check migrations:", err)
+	} else if pending > 0 {
+		log.Printf("%d pending migration(s), run `go run main.go migrate up`", pending)
+	}
+
+	store := models.NewGormStore(db)
+
+	// Expire the ended suspensions in the background
+	go workers.NewSuspensionExpiryWorker(store, time.Minute).Run(context.Background())
+
+	resolver, err := notificationResolver(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	channel, err := deliveryChannel()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Run the background jobs (e.g. sending the scheduled notifications and the deliveries)
+	queue := jobs.NewQueue(store)
+	delivery.NewDispatcher(store, channel).Register(queue)
+	notifications.NewScheduler(resolver).Register(queue)
+	go queue.Run(context.Background(), jobWorkers())
+
+	controller := controllers.NewController(store)
+	controller.Resolver = resolver
+	controller.Auth = authenticator(store)
+	if len(controller.Auth.Secret) == 0 {
+		log.Println("JWT_SECRET is not set, only API keys are accepted")
+	}
+	handler := controllers.Router(controller)
+
+	err = http.ListenAndServe(":3333", handler)
 	if err != nil {
 		fmt.Println("Server died...")
 	}
+}
+
+// Handles the `migrate up|down [steps]|status` subcommands
+func migrate(db *gorm.DB, args []string) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command, expected: up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up()
+		fmt.Printf("Applied %d migration(s)\n", count)
+		return err
+	case "down":
+		// Rolls back the last migration unless a number of steps is given
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		count, err := migrator.Down(steps)
+		fmt.Printf("Rolled back %d migration(s)\n", count)
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Printf("%04d_%s\tapplied at %s\n", status.Version, status.Name, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", status.Version, status.Name)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q, expected: up|down [steps]|status", args[0])
+}
+
+// Handles the `apikey create <teacher> [name]` subcommand, which prints the new key
+func apiKey(db *gorm.DB, args []string) error {
+	if len(args) < 2 || args[0] != "create" {
+		return fmt.Errorf("expected: apikey create <teacher> [name]")
+	}
+	name := ""
+	if len(args) > 2 {
+		name = args[2]
+	}
+
+	key, _, err := auth.IssueAPIKey(models.NewGormStore(db), strings.ToLower(args[1]), name)
+	if errors.Is(err, models.ErrNotFound) {
+		return fmt.Errorf("teacher %q does not exist", args[1])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+// Handles the `role <teacher> <role>` subcommand, used to grant the first admin
+func role(db *gorm.DB, args []string) error {
+	if len(args) != 2 || !models.ValidRole(args[1]) {
+		return fmt.Errorf("expected: role <teacher> admin|teacher|auditor")
+	}
 
+	teacher, err := models.NewGormStore(db).UpdateTeacherRole(strings.ToLower(args[0]), args[1])
+	if errors.Is(err, models.ErrNotFound) {
+		return fmt.Errorf("teacher %q does not exist", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", teacher.Email, teacher.Role)
+	return nil
+}
+
+// Handles the `password reset-token <teacher>` subcommand, which prints a token the teacher sets their password with
+func password(db *gorm.DB, args []string) error {
+	if len(args) != 2 || args[0] != "reset-token" {
+		return fmt.Errorf("expected: password reset-token <teacher>")
+	}
+
+	token, _, err := auth.IssuePasswordReset(models.NewGormStore(db), strings.ToLower(args[1]))
+	if errors.Is(err, models.ErrNotFound) {
+		return fmt.Errorf("teacher %q does not exist", args[1])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
 }
@@ -0,0 +1,111 @@
+package mentions
+
+import "strings"
+
+// Kinds of group mentions
+const (
+	GroupClass  = "class"
+	GroupCohort = "cohort"
+	// @all-my-students, every student registered under the teacher
+	GroupAllMyStudents = "all-my-students"
+)
+
+const maxGroupNameLength = 64
+
+// Group @mentioned in a text, e.g. @class:3A or @all-my-students
+type Group struct {
+	Kind string
+	// Empty for @all-my-students
+	Name string
+}
+
+// Returns the group as it is written after the '@', e.g. class:3A
+func (g Group) String() string {
+	if g.Name == "" {
+		return g.Kind
+	}
+	return g.Kind + ":" + g.Name
+}
+
+// Returns the @mentioned groups without duplicates and in order of first appearance,
+// the group names are matched case insensitively
+func ParseGroups(text string) []Group {
+	seen := make(map[string]bool)
+	groups := []Group{}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' || (i > 0 && isWordChar(text[i-1])) {
+			continue
+		}
+		group, end := parseGroup(text, i+1)
+		if group.Kind == "" {
+			continue
+		}
+		key := strings.ToLower(group.String())
+		if !seen[key] {
+			seen[key] = true
+			groups = append(groups, group)
+		}
+		i = end - 1
+	}
+	return groups
+}
+
+// Checks if a class or cohort name can be @mentioned
+func ValidGroupName(name string) bool {
+	if name == "" || len(name) > maxGroupNameLength || name[len(name)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isGroupNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses the group starting at text[start], returns it along with the index following it
+// or an empty group if there is no valid group there
+func parseGroup(text string, start int) (Group, int) {
+	rest := text[start:]
+
+	if strings.HasPrefix(rest, GroupAllMyStudents) {
+		end := start + len(GroupAllMyStudents)
+		// Not part of a longer word or of an email (e.g. @all-my-students-2027 or @all-my-students@x.io),
+		// a '.' ending the sentence is punctuation (e.g. "to @all-my-students." or "@all-my-students. Also")
+		if end < len(text) && (isLocalChar(text[end]) || text[end] == '@') && !endsSentence(text, end) {
+			return Group{}, start
+		}
+		return Group{Kind: GroupAllMyStudents}, end
+	}
+
+	for _, kind := range []string{GroupClass, GroupCohort} {
+		if !strings.HasPrefix(rest, kind+":") {
+			continue
+		}
+		nameStart := start + len(kind) + 1
+		end := nameStart
+		for end < len(text) && isGroupNameChar(text[end]) {
+			end++
+		}
+		// Trailing punctuation ends the sentence, it is not part of the name
+		for end > nameStart && text[end-1] == '-' {
+			end--
+		}
+		name := text[nameStart:end]
+		if !ValidGroupName(name) {
+			return Group{}, start
+		}
+		return Group{Kind: kind, Name: name}, end
+	}
+	return Group{}, start
+}
+
+// Whether text[i] is a '.' followed by a space or the end of the text
+func endsSentence(text string, i int) bool {
+	return text[i] == '.' && (i+1 == len(text) || strings.ContainsRune(" \t\r\n", rune(text[i+1])))
+}
+
+func isGroupNameChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '_' || c == '-'
+}
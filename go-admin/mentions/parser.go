@@ -0,0 +1,117 @@
+// Package mentions extracts the @mentioned email addresses from a notification.
+//
+// A mention is an '@' followed by an address in the RFC 5322 dot-atom form
+// (e.g. @jane.doe@school.edu.sg, @a+b@x.io). Quoted local parts, IP literals and
+// non ASCII addresses are not supported.
+package mentions
+
+import "strings"
+
+const (
+	maxLocalLength  = 64
+	maxDomainLength = 253
+	maxLabelLength  = 63
+)
+
+// Returns the @mentioned emails lowercased, without duplicates and in order of first appearance
+func Parse(text string) []string {
+	seen := make(map[string]bool)
+	emails := []string{}
+
+	for i := 0; i < len(text); i++ {
+		// Part of a plain address (e.g. jane@x.io) rather than a mention
+		if text[i] != '@' || (i > 0 && isWordChar(text[i-1])) {
+			continue
+		}
+		email, end := parseAddress(text, i+1)
+		if email == "" {
+			continue
+		}
+		email = strings.ToLower(email)
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+		i = end - 1
+	}
+	return emails
+}
+
+// Parses the address starting at text[start], returns it along with the index following it
+// or an empty string if there is no valid address there
+func parseAddress(text string, start int) (string, int) {
+	at := start
+	for at < len(text) && isLocalChar(text[at]) {
+		at++
+	}
+	if at == len(text) || text[at] != '@' || !validLocal(text[start:at]) {
+		return "", start
+	}
+
+	end := at + 1
+	for end < len(text) && isDomainChar(text[end]) {
+		end++
+	}
+	// Trailing punctuation ends the sentence, it is not part of the domain
+	for end > at+1 && (text[end-1] == '.' || text[end-1] == '-') {
+		end--
+	}
+	if !validDomain(text[at+1 : end]) {
+		return "", start
+	}
+	return text[start:end], end
+}
+
+func validLocal(local string) bool {
+	if local == "" || len(local) > maxLocalLength {
+		return false
+	}
+	return local[0] != '.' && local[len(local)-1] != '.' && !strings.Contains(local, "..")
+}
+
+// The domain needs at least two labels and an alphabetic top level domain (e.g. school.edu.sg)
+func validDomain(domain string) bool {
+	if len(domain) > maxDomainLength {
+		return false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+	}
+	tld := labels[len(labels)-1]
+	if len(tld) < 2 {
+		return false
+	}
+	for i := 0; i < len(tld); i++ {
+		if !isLetter(tld[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// atext characters of RFC 5322 along with the dot separating them
+func isLocalChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '.' || strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+func isWordChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || strings.IndexByte("._+-", c) >= 0
+}
+
+func isDomainChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '-' || c == '.'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
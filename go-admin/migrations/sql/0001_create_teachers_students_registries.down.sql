@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS registries;
+DROP TABLE IF EXISTS students;
+DROP TABLE IF EXISTS teachers;
+
+DROP FUNCTION IF EXISTS trigger_set_timestamp();
@@ -0,0 +1,107 @@
+-- Idempotent so that the databases created before the migrations (by init.sql or GORM's AutoMigrate) are adopted:
+-- the missing tables are created, then the existing ones are reconciled with the schema the next migrations expect
+
+-- FUNCTION STATEMENTS
+CREATE OR REPLACE FUNCTION trigger_set_timestamp()
+RETURNS TRIGGER AS $$
+BEGIN
+  NEW.updated_at = NOW();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- CREATE TABLE STATEMENTS
+CREATE TABLE IF NOT EXISTS teachers (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    name VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS students (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    name VARCHAR(255),
+    suspended INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS registries (
+    id SERIAL,
+    teacher_email VARCHAR(255) NOT NULL,
+    student_email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (teacher_email, student_email),
+    FOREIGN KEY (teacher_email) REFERENCES teachers(email),
+    FOREIGN KEY (student_email) REFERENCES students(email)
+);
+
+-- RECONCILE STATEMENTS (no-ops on the tables created above)
+-- AutoMigrate created text columns without defaults, init.sql left students.suspended nullable
+UPDATE teachers SET created_at = COALESCE(created_at, NOW()), updated_at = COALESCE(updated_at, NOW())
+WHERE created_at IS NULL OR updated_at IS NULL;
+ALTER TABLE teachers
+    ALTER COLUMN email TYPE VARCHAR(255),
+    ALTER COLUMN name TYPE VARCHAR(255),
+    ALTER COLUMN created_at SET DEFAULT NOW(),
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT NOW(),
+    ALTER COLUMN updated_at SET NOT NULL;
+
+UPDATE students SET suspended = COALESCE(suspended, 0), created_at = COALESCE(created_at, NOW()), updated_at = COALESCE(updated_at, NOW())
+WHERE suspended IS NULL OR created_at IS NULL OR updated_at IS NULL;
+ALTER TABLE students
+    ALTER COLUMN email TYPE VARCHAR(255),
+    ALTER COLUMN name TYPE VARCHAR(255),
+    ALTER COLUMN suspended TYPE INTEGER,
+    ALTER COLUMN suspended SET DEFAULT 0,
+    ALTER COLUMN suspended SET NOT NULL,
+    ALTER COLUMN created_at SET DEFAULT NOW(),
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT NOW(),
+    ALTER COLUMN updated_at SET NOT NULL;
+
+-- AutoMigrate made the id part of the primary key, so a student could be registered twice under a teacher (the first
+-- registry is kept), and created no foreign keys, so the registries of unknown teachers or students are removed
+DELETE FROM registries r USING registries d
+WHERE r.teacher_email = d.teacher_email AND r.student_email = d.student_email AND r.id > d.id;
+DELETE FROM registries r
+WHERE NOT EXISTS (SELECT 1 FROM teachers t WHERE t.email = r.teacher_email)
+   OR NOT EXISTS (SELECT 1 FROM students s WHERE s.email = r.student_email);
+UPDATE registries SET created_at = COALESCE(created_at, NOW()), updated_at = COALESCE(updated_at, NOW())
+WHERE created_at IS NULL OR updated_at IS NULL;
+ALTER TABLE registries
+    DROP CONSTRAINT IF EXISTS registries_pkey,
+    DROP CONSTRAINT IF EXISTS registries_teacher_email_fkey,
+    DROP CONSTRAINT IF EXISTS registries_student_email_fkey,
+    ALTER COLUMN teacher_email TYPE VARCHAR(255),
+    ALTER COLUMN student_email TYPE VARCHAR(255),
+    ALTER COLUMN created_at SET DEFAULT NOW(),
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT NOW(),
+    ALTER COLUMN updated_at SET NOT NULL,
+    ADD CONSTRAINT registries_pkey PRIMARY KEY (teacher_email, student_email),
+    ADD CONSTRAINT registries_teacher_email_fkey FOREIGN KEY (teacher_email) REFERENCES teachers(email),
+    ADD CONSTRAINT registries_student_email_fkey FOREIGN KEY (student_email) REFERENCES students(email);
+
+-- CREATE TRIGGERS STATEMENTS
+DROP TRIGGER IF EXISTS set_timestamp_teachers ON teachers;
+CREATE TRIGGER set_timestamp_teachers
+BEFORE UPDATE ON teachers
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+DROP TRIGGER IF EXISTS set_timestamp_students ON students;
+CREATE TRIGGER set_timestamp_students
+BEFORE UPDATE ON students
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+DROP TRIGGER IF EXISTS set_timestamp_registries ON registries;
+CREATE TRIGGER set_timestamp_registries
+BEFORE UPDATE ON registries
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
@@ -0,0 +1,5 @@
+ALTER TABLE registries
+    DROP CONSTRAINT registries_teacher_email_fkey,
+    DROP CONSTRAINT registries_student_email_fkey,
+    ADD CONSTRAINT registries_teacher_email_fkey FOREIGN KEY (teacher_email) REFERENCES teachers(email),
+    ADD CONSTRAINT registries_student_email_fkey FOREIGN KEY (student_email) REFERENCES students(email);
@@ -0,0 +1,6 @@
+-- Deleting a teacher or a student also removes their registries
+ALTER TABLE registries
+    DROP CONSTRAINT registries_teacher_email_fkey,
+    DROP CONSTRAINT registries_student_email_fkey,
+    ADD CONSTRAINT registries_teacher_email_fkey FOREIGN KEY (teacher_email) REFERENCES teachers(email) ON DELETE CASCADE,
+    ADD CONSTRAINT registries_student_email_fkey FOREIGN KEY (student_email) REFERENCES students(email) ON DELETE CASCADE;
@@ -0,0 +1,11 @@
+ALTER TABLE students ADD COLUMN suspended INTEGER NOT NULL DEFAULT 0;
+
+UPDATE students SET suspended = 1
+WHERE email IN (
+    SELECT student_email FROM suspensions
+    WHERE starts_at <= NOW()
+    AND (ends_at IS NULL OR ends_at > NOW())
+    AND (lifted_at IS NULL OR lifted_at > NOW())
+);
+
+DROP TABLE IF EXISTS suspensions;
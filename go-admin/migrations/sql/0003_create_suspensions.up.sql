@@ -0,0 +1,26 @@
+CREATE TABLE suspensions (
+    id SERIAL PRIMARY KEY,
+    student_email VARCHAR(255) NOT NULL REFERENCES students(email) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ends_at TIMESTAMPTZ,
+    lifted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX suspensions_student_email_idx ON suspensions (student_email);
+-- Used by the worker lifting expired suspensions
+CREATE INDEX suspensions_pending_expiry_idx ON suspensions (ends_at) WHERE lifted_at IS NULL;
+
+CREATE TRIGGER set_timestamp_suspensions
+BEFORE UPDATE ON suspensions
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- Carry over the students currently flagged as suspended
+INSERT INTO suspensions (student_email, starts_at)
+SELECT email, updated_at FROM students WHERE suspended = 1;
+
+ALTER TABLE students DROP COLUMN suspended;
@@ -0,0 +1,38 @@
+CREATE TABLE suspensions (
+    id SERIAL PRIMARY KEY,
+    student_email VARCHAR(255) NOT NULL REFERENCES students(email) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ends_at TIMESTAMPTZ,
+    lifted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX suspensions_student_email_idx ON suspensions (student_email);
+CREATE INDEX suspensions_pending_expiry_idx ON suspensions (ends_at) WHERE lifted_at IS NULL;
+
+CREATE TRIGGER set_timestamp_suspensions
+BEFORE UPDATE ON suspensions
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- A suspension is lifted by its expire event or by the first unsuspend event recorded after it
+INSERT INTO suspensions (id, student_email, reason, starts_at, ends_at, lifted_at, created_at)
+SELECT s.id, s.student_email, s.reason, COALESCE(s.starts_at, s.created_at), s.ends_at,
+    COALESCE(
+        (SELECT MIN(e.created_at) FROM suspension_events e WHERE e.action = 'expire' AND e.suspension_id = s.id),
+        (SELECT MIN(u.created_at) FROM suspension_events u
+         WHERE u.action = 'unsuspend' AND u.student_email = s.student_email AND u.id > s.id
+         AND (s.ends_at IS NULL OR u.created_at < s.ends_at))
+    ),
+    s.created_at
+FROM suspension_events s
+WHERE s.action = 'suspend'
+ORDER BY s.id;
+
+SELECT setval(pg_get_serial_sequence('suspensions', 'id'), COALESCE((SELECT MAX(id) FROM suspensions), 0) + 1, false);
+
+DROP TABLE IF EXISTS suspension_events;
+DROP FUNCTION IF EXISTS trigger_reject_update();
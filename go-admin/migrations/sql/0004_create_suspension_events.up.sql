@@ -0,0 +1,50 @@
+-- Immutable suspension history, the current suspension state is derived from it
+CREATE TABLE suspension_events (
+    id SERIAL PRIMARY KEY,
+    student_email VARCHAR(255) NOT NULL REFERENCES students(email) ON DELETE CASCADE,
+    action VARCHAR(16) NOT NULL CHECK (action IN ('suspend', 'unsuspend', 'expire')),
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    suspension_id INTEGER REFERENCES suspension_events(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at IS NULL OR starts_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX suspension_events_student_email_idx ON suspension_events (student_email, id);
+CREATE INDEX suspension_events_suspension_id_idx ON suspension_events (suspension_id);
+
+CREATE OR REPLACE FUNCTION trigger_reject_update()
+RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION '% rows are immutable', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reject_update_suspension_events
+BEFORE UPDATE ON suspension_events
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_reject_update();
+
+-- Carry over the existing suspensions, keeping their ids
+INSERT INTO suspension_events (id, student_email, action, actor, reason, starts_at, ends_at, created_at)
+SELECT id, student_email, 'suspend', 'migration', reason, starts_at, ends_at, created_at
+FROM suspensions
+ORDER BY id;
+
+SELECT setval(pg_get_serial_sequence('suspension_events', 'id'), COALESCE((SELECT MAX(id) FROM suspension_events), 0) + 1, false);
+
+INSERT INTO suspension_events (student_email, action, actor, suspension_id, created_at)
+SELECT student_email, 'expire', 'migration', id, lifted_at
+FROM suspensions
+WHERE lifted_at IS NOT NULL AND ends_at IS NOT NULL AND lifted_at >= ends_at
+ORDER BY id;
+
+INSERT INTO suspension_events (student_email, action, actor, created_at)
+SELECT student_email, 'unsuspend', 'migration', lifted_at
+FROM suspensions
+WHERE lifted_at IS NOT NULL AND (ends_at IS NULL OR lifted_at < ends_at)
+ORDER BY id;
+
+DROP TABLE suspensions;
@@ -0,0 +1,17 @@
+CREATE TABLE notifications (
+    id VARCHAR(32) PRIMARY KEY,
+    teacher_email VARCHAR(255) NOT NULL REFERENCES teachers(email) ON DELETE CASCADE,
+    text TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notifications_teacher_email_created_at_idx ON notifications (teacher_email, created_at);
+CREATE INDEX notifications_created_at_idx ON notifications (created_at);
+
+-- No foreign key on student_email, the history is kept when a student is deleted
+CREATE TABLE notification_recipients (
+    notification_id VARCHAR(32) NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    student_email VARCHAR(255) NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (notification_id, student_email)
+);
@@ -0,0 +1,20 @@
+CREATE TABLE deliveries (
+    id SERIAL PRIMARY KEY,
+    notification_id VARCHAR(32) NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    student_email VARCHAR(255) NOT NULL,
+    channel VARCHAR(32) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX deliveries_notification_id_idx ON deliveries (notification_id);
+CREATE INDEX deliveries_pending_idx ON deliveries (id) WHERE status = 'pending';
+
+CREATE TRIGGER set_timestamp_deliveries
+BEFORE UPDATE ON deliveries
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
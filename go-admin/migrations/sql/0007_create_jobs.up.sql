@@ -0,0 +1,22 @@
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL DEFAULT '{}',
+    status VARCHAR(16) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Used by the workers claiming the next job
+CREATE INDEX jobs_queued_run_at_idx ON jobs (run_at) WHERE status = 'queued';
+CREATE INDEX jobs_status_idx ON jobs (status);
+
+CREATE TRIGGER set_timestamp_jobs
+BEFORE UPDATE ON jobs
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
@@ -0,0 +1,7 @@
+-- The scheduled and cancelled notifications have no meaning without these columns
+DELETE FROM notifications WHERE status <> 'sent';
+
+ALTER TABLE notifications
+    DROP COLUMN status,
+    DROP COLUMN send_at,
+    DROP COLUMN sent_at;
@@ -0,0 +1,8 @@
+ALTER TABLE notifications
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'sent' CHECK (status IN ('scheduled', 'sent', 'cancelled')),
+    ADD COLUMN send_at TIMESTAMPTZ,
+    ADD COLUMN sent_at TIMESTAMPTZ;
+
+UPDATE notifications SET sent_at = created_at;
+
+CREATE INDEX notifications_scheduled_send_at_idx ON notifications (send_at) WHERE status = 'scheduled';
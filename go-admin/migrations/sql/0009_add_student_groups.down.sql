@@ -0,0 +1,3 @@
+ALTER TABLE students
+    DROP COLUMN class_name,
+    DROP COLUMN cohort;
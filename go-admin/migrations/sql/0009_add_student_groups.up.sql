@@ -0,0 +1,6 @@
+ALTER TABLE students
+    ADD COLUMN class_name VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN cohort VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX students_class_name_idx ON students (lower(class_name)) WHERE class_name <> '';
+CREATE INDEX students_cohort_idx ON students (lower(cohort)) WHERE cohort <> '';
@@ -0,0 +1,35 @@
+CREATE TABLE registries (
+    id SERIAL,
+    teacher_email VARCHAR(255) NOT NULL REFERENCES teachers(email) ON DELETE CASCADE,
+    student_email VARCHAR(255) NOT NULL REFERENCES students(email) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (teacher_email, student_email)
+);
+
+CREATE TRIGGER set_timestamp_registries
+BEFORE UPDATE ON registries
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- A student is registered under every teacher of the classes they are enrolled in
+INSERT INTO registries (teacher_email, student_email, created_at)
+SELECT ct.teacher_email, e.student_email, MIN(e.created_at)
+FROM enrollments e
+JOIN class_teachers ct ON ct.class_id = e.class_id
+GROUP BY ct.teacher_email, e.student_email;
+
+ALTER TABLE students ADD COLUMN class_name VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX students_class_name_idx ON students (lower(class_name)) WHERE class_name <> '';
+
+-- A student enrolled in several classes keeps one of them, the teachers' own classes are left out
+UPDATE students s
+SET class_name = c.code
+FROM enrollments e
+JOIN classes c ON c.id = e.class_id
+WHERE e.student_email = s.email AND c.code NOT LIKE '%@%';
+
+DROP TABLE enrollments;
+DROP TABLE class_teachers;
+DROP TABLE classes;
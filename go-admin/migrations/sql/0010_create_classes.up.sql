@@ -0,0 +1,62 @@
+CREATE TABLE classes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Class codes are matched case insensitively
+CREATE UNIQUE INDEX classes_code_idx ON classes (lower(code));
+
+CREATE TRIGGER set_timestamp_classes
+BEFORE UPDATE ON classes
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+CREATE TABLE class_teachers (
+    class_id INTEGER NOT NULL REFERENCES classes(id) ON DELETE CASCADE,
+    teacher_email VARCHAR(255) NOT NULL REFERENCES teachers(email) ON DELETE CASCADE,
+    PRIMARY KEY (class_id, teacher_email)
+);
+
+CREATE INDEX class_teachers_teacher_email_idx ON class_teachers (teacher_email);
+
+CREATE TABLE enrollments (
+    class_id INTEGER NOT NULL REFERENCES classes(id) ON DELETE CASCADE,
+    student_email VARCHAR(255) NOT NULL REFERENCES students(email) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (class_id, student_email)
+);
+
+CREATE INDEX enrollments_student_email_idx ON enrollments (student_email);
+
+-- The registries of each teacher become the enrollments of the teacher's own class, whose code is the teacher's email
+INSERT INTO classes (code, name)
+SELECT t.email, t.email FROM teachers t
+WHERE EXISTS (SELECT 1 FROM registries r WHERE r.teacher_email = t.email);
+
+INSERT INTO class_teachers (class_id, teacher_email)
+SELECT c.id, c.code FROM classes c;
+
+INSERT INTO enrollments (class_id, student_email, created_at)
+SELECT c.id, r.student_email, r.created_at
+FROM registries r
+JOIN classes c ON c.code = r.teacher_email;
+
+-- The classes set on the students become classes of their own (without teachers)
+INSERT INTO classes (code, name)
+SELECT DISTINCT ON (lower(class_name)) class_name, class_name
+FROM students
+WHERE class_name <> ''
+ORDER BY lower(class_name), class_name;
+
+INSERT INTO enrollments (class_id, student_email)
+SELECT c.id, s.email
+FROM students s
+JOIN classes c ON lower(c.code) = lower(s.class_name)
+WHERE s.class_name <> '';
+
+ALTER TABLE students DROP COLUMN class_name;
+
+DROP TABLE registries;
@@ -0,0 +1,11 @@
+-- Only the enrollments of the current term are kept
+DELETE FROM enrollments WHERE term_id IS DISTINCT FROM (SELECT id FROM terms WHERE is_current);
+
+DROP INDEX enrollments_class_id_idx;
+
+ALTER TABLE enrollments
+    DROP CONSTRAINT enrollments_pkey,
+    DROP COLUMN term_id,
+    ADD PRIMARY KEY (class_id, student_email);
+
+DROP TABLE terms;
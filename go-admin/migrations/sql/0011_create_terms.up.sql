@@ -0,0 +1,33 @@
+CREATE TABLE terms (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) UNIQUE NOT NULL,
+    starts_on TIMESTAMPTZ,
+    ends_on TIMESTAMPTZ,
+    is_current BOOLEAN NOT NULL DEFAULT FALSE,
+    archived_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (NOT (is_current AND archived_at IS NOT NULL))
+);
+
+-- Only one term is current at a time
+CREATE UNIQUE INDEX terms_is_current_idx ON terms (is_current) WHERE is_current;
+
+CREATE TRIGGER set_timestamp_terms
+BEFORE UPDATE ON terms
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- The existing enrollments belong to a first term, named after the current year
+INSERT INTO terms (name, is_current) VALUES (to_char(NOW(), 'YYYY'), TRUE);
+
+ALTER TABLE enrollments ADD COLUMN term_id INTEGER REFERENCES terms(id) ON DELETE CASCADE;
+
+UPDATE enrollments SET term_id = (SELECT id FROM terms WHERE is_current);
+
+ALTER TABLE enrollments
+    ALTER COLUMN term_id SET NOT NULL,
+    DROP CONSTRAINT enrollments_pkey,
+    ADD PRIMARY KEY (term_id, class_id, student_email);
+
+CREATE INDEX enrollments_class_id_idx ON enrollments (class_id);
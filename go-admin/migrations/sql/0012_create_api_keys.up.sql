@@ -0,0 +1,12 @@
+-- Only the SHA-256 hash of a key is stored, the key itself is only shown when it is created
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    teacher_email VARCHAR(255) NOT NULL REFERENCES teachers(email) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    prefix VARCHAR(16) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_teacher_email_idx ON api_keys (teacher_email);
@@ -0,0 +1,3 @@
+-- Role of the account, checked against the permissions of the routes
+ALTER TABLE teachers
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'teacher' CHECK (role IN ('admin', 'teacher', 'auditor'));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS sessions;
+
+ALTER TABLE teachers
+    DROP COLUMN password_hash;
@@ -0,0 +1,26 @@
+-- bcrypt hash, empty until the teacher sets a password with a password reset token
+ALTER TABLE teachers
+    ADD COLUMN password_hash VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Only the SHA-256 hashes of the session tokens are stored
+CREATE TABLE sessions (
+    id CHAR(32) PRIMARY KEY,
+    teacher_email VARCHAR(255) NOT NULL REFERENCES teachers(email) ON DELETE CASCADE,
+    access_hash CHAR(64) UNIQUE NOT NULL,
+    refresh_hash CHAR(64) UNIQUE NOT NULL,
+    access_expires_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX sessions_teacher_email_idx ON sessions (teacher_email);
+
+CREATE TABLE password_resets (
+    hash CHAR(64) PRIMARY KEY,
+    teacher_email VARCHAR(255) NOT NULL REFERENCES teachers(email) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE sessions
+    DROP COLUMN two_factor_at;
+
+ALTER TABLE teachers
+    DROP COLUMN totp_secret,
+    DROP COLUMN totp_enabled,
+    DROP COLUMN totp_last_step;
@@ -0,0 +1,19 @@
+-- The TOTP secret is set when the teacher enrolls, it is only used once they confirmed it with a code
+ALTER TABLE teachers
+    ADD COLUMN totp_secret VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE sessions
+    ADD COLUMN two_factor_at TIMESTAMPTZ;
+
+-- Only the SHA-256 hashes of the recovery codes are stored
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    teacher_email VARCHAR(255) NOT NULL REFERENCES teachers(email) ON DELETE CASCADE,
+    hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX recovery_codes_teacher_email_idx ON recovery_codes (teacher_email);
@@ -0,0 +1,34 @@
+-- Append-only log of the mutating API calls, kept when the teachers are deleted
+CREATE TABLE audit_entries (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_entries_actor_idx ON audit_entries (actor);
+CREATE INDEX audit_entries_action_idx ON audit_entries (action);
+CREATE INDEX audit_entries_target_idx ON audit_entries (target);
+CREATE INDEX audit_entries_request_id_idx ON audit_entries (request_id);
+CREATE INDEX audit_entries_created_at_idx ON audit_entries (created_at);
+
+CREATE OR REPLACE FUNCTION reject_audit_entry_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_entries_append_only
+BEFORE UPDATE OR DELETE ON audit_entries
+FOR EACH ROW
+EXECUTE PROCEDURE reject_audit_entry_changes();
+
+CREATE TRIGGER audit_entries_no_truncate
+BEFORE TRUNCATE ON audit_entries
+FOR EACH STATEMENT
+EXECUTE PROCEDURE reject_audit_entry_changes();
@@ -0,0 +1,11 @@
+ALTER TABLE suspension_events
+    DROP CONSTRAINT suspension_events_student_email_fkey,
+    ADD CONSTRAINT suspension_events_student_email_fkey FOREIGN KEY (student_email) REFERENCES students(email) ON DELETE CASCADE;
+
+DROP TRIGGER reject_truncate_suspension_events ON suspension_events;
+DROP TRIGGER reject_changes_suspension_events ON suspension_events;
+
+CREATE TRIGGER reject_update_suspension_events
+BEFORE UPDATE ON suspension_events
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_reject_update();
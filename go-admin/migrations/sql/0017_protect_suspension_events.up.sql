@@ -0,0 +1,16 @@
+-- The suspension history cannot be erased: its events are never deleted, nor the students who have some
+DROP TRIGGER reject_update_suspension_events ON suspension_events;
+
+CREATE TRIGGER reject_changes_suspension_events
+BEFORE UPDATE OR DELETE ON suspension_events
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_reject_update();
+
+CREATE TRIGGER reject_truncate_suspension_events
+BEFORE TRUNCATE ON suspension_events
+FOR EACH STATEMENT
+EXECUTE PROCEDURE trigger_reject_update();
+
+ALTER TABLE suspension_events
+    DROP CONSTRAINT suspension_events_student_email_fkey,
+    ADD CONSTRAINT suspension_events_student_email_fkey FOREIGN KEY (student_email) REFERENCES students(email) ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+ALTER TABLE notifications
+    DROP CONSTRAINT notifications_teacher_email_fkey,
+    ADD CONSTRAINT notifications_teacher_email_fkey FOREIGN KEY (teacher_email) REFERENCES teachers(email) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+-- The notification history is kept, the teachers who sent notifications cannot be deleted
+ALTER TABLE notifications
+    DROP CONSTRAINT notifications_teacher_email_fkey,
+    ADD CONSTRAINT notifications_teacher_email_fkey FOREIGN KEY (teacher_email) REFERENCES teachers(email) ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+ALTER TABLE teachers
+    DROP COLUMN two_factor_failures,
+    DROP COLUMN two_factor_locked_until;
@@ -0,0 +1,4 @@
+-- The two-factor authentication of a teacher is locked for a while after too many codes rejected in a row
+ALTER TABLE teachers
+    ADD COLUMN two_factor_failures INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN two_factor_locked_until TIMESTAMPTZ;
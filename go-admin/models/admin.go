@@ -1,28 +1,414 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
 
 type Teacher struct {
-	ID        uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
-	Email     string    `json:"email" gorm:"unique;not null"`
-	Name      string    `json:"name"`
+	ID    uint   `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	Email string `json:"email" gorm:"unique;not null"`
+	Name  string `json:"name"`
+	// admin, teacher or auditor, see the Role constants
+	Role string `json:"role" gorm:"not null;default:teacher"`
+	// bcrypt hash of the teacher's password, empty until they set one with a password reset token
+	PasswordHash string `json:"-"`
+	// Base32 TOTP secret, set when the teacher enrolls and only used once they confirmed it (TOTPEnabled)
+	TOTPSecret  string `json:"-" gorm:"column:totp_secret"`
+	TOTPEnabled bool   `json:"totp_enabled" gorm:"column:totp_enabled"`
+	// Time step of the last TOTP code accepted, a code cannot be used twice
+	TOTPLastStep int64 `json:"-" gorm:"column:totp_last_step"`
+	// Codes rejected in a row, the two-factor authentication is locked until TwoFactorLockedUntil after too many
+	TwoFactorFailures    int        `json:"-" gorm:"column:two_factor_failures"`
+	TwoFactorLockedUntil *time.Time `json:"-" gorm:"column:two_factor_locked_until"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// Roles of the accounts calling the API
+const (
+	// Manages the directory, the suspensions and the jobs, may act as any teacher
+	RoleAdmin = "admin"
+	// Registers students and sends notifications as themselves
+	RoleTeacher = "teacher"
+	// Reads the directory and the histories, cannot change anything
+	RoleAuditor = "auditor"
+)
+
+// Whether the role is one of the Role constants
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleTeacher || role == RoleAuditor
+}
+
+// Filters used when listing teachers, empty fields are ignored
+type TeacherFilter struct {
+	NamePrefix string // Case insensitive
+}
+
+type Student struct {
+	ID    uint   `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	Email string `json:"email" gorm:"unique;not null"`
+	Name  string `json:"name"`
+	// Group the student can be @mentioned by (e.g. @cohort:2027), empty if not set
+	Cohort    string    `json:"cohort"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type Student struct {
+// Fields changed by a student update, nil fields are left as they are
+type StudentChanges struct {
+	Name   *string
+	Cohort *string
+}
+
+// Filters used when listing students, empty fields are ignored
+type StudentFilter struct {
+	NamePrefix string // Case insensitive
+	// Keeps the students suspended (or not) at SuspendedAt
+	Suspended   *bool
+	SuspendedAt time.Time
+}
+
+// Class taught by one or more teachers (e.g. MATH-3A), the code is unique (case insensitive)
+type Class struct {
 	ID        uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
-	Email     string    `json:"email" gorm:"unique;not null"`
+	Code      string    `json:"code" gorm:"not null"`
 	Name      string    `json:"name"`
-	Suspended int       `json:"suspended" gorm:"default:0"` // 0: Not suspended or 1: Suspended
+	Teachers  []string  `json:"teachers" gorm:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type Registry struct {
-	ID           uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
-	TeacherEmail string    `json:"teacher_email" gorm:"primary_key"`
-	StudentEmail string    `json:"student_email" gorm:"primary_key"`
+// Filters used when listing classes, empty fields are ignored
+type ClassFilter struct {
+	TeacherEmail string
+	NamePrefix   string // Case insensitive
+}
+
+// Teacher of a class, the primary key is (class_id, teacher_email)
+type ClassTeacher struct {
+	ClassID      uint   `gorm:"primaryKey;autoIncrement:false"`
+	TeacherEmail string `gorm:"primaryKey"`
+}
+
+// Student enrolled in a class during a term, the primary key is (term_id, class_id, student_email)
+type Enrollment struct {
+	TermID       uint      `json:"term_id" gorm:"primaryKey;autoIncrement:false"`
+	ClassID      uint      `json:"class_id" gorm:"primaryKey;autoIncrement:false"`
+	StudentEmail string    `json:"student" gorm:"primaryKey"`
 	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Academic term (e.g. 2026-T1), the enrollments are scoped to a term.
+// Only one term is current at a time, archived terms are read only.
+type Term struct {
+	ID         uint       `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	Name       string     `json:"name" gorm:"unique;not null"`
+	StartsOn   *time.Time `json:"starts_on"`
+	EndsOn     *time.Time `json:"ends_on"`
+	Current    bool       `json:"current" gorm:"column:is_current"`
+	ArchivedAt *time.Time `json:"archived_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (t *Term) Archived() bool {
+	return t.ArchivedAt != nil
+}
+
+// Code of the class backing the teacher-level registrations (/api/register without a class).
+// Teacher emails are not valid class codes so it cannot clash with the other classes.
+func TeacherClassCode(teacherEmail string) string {
+	return teacherEmail
+}
+
+// Actions recorded in the suspension history
+const (
+	SuspendAction   = "suspend"
+	UnSuspendAction = "unsuspend"
+	ExpireAction    = "expire"
+)
+
+// Immutable entry of a student's suspension history, the current state is derived from these events.
+// An unsuspend event lifts every suspension recorded before it which has not ended yet,
+// an expire event records that the suspension SuspensionID reached its EndsAt.
+type SuspensionEvent struct {
+	ID           uint       `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	StudentEmail string     `json:"student_email" gorm:"not null"`
+	Action       string     `json:"action" gorm:"not null"`
+	Actor        string     `json:"actor"`
+	Reason       string     `json:"reason"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	SuspensionID *uint      `json:"suspension_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Suspension derived from the history, its ID is the ID of the suspend event.
+// A student is suspended between StartsAt and EndsAt (no EndsAt => until it is lifted)
+type Suspension struct {
+	ID           uint       `json:"id"`
+	StudentEmail string     `json:"student_email"`
+	Reason       string     `json:"reason"`
+	SuspendedBy  string     `json:"suspended_by"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	LiftedAt     *time.Time `json:"lifted_at"`
+	LiftedBy     string     `json:"lifted_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Checks if the suspension applies at the given time
+func (s *Suspension) ActiveAt(at time.Time) bool {
+	if s.LiftedAt != nil && !s.LiftedAt.After(at) {
+		return false
+	}
+	if s.StartsAt.After(at) {
+		return false
+	}
+	return s.EndsAt == nil || s.EndsAt.After(at)
+}
+
+// Replays a student's events (ordered by ID) into their suspensions
+func DeriveSuspensions(events []SuspensionEvent) []Suspension {
+	suspensions := []Suspension{}
+	for _, event := range events {
+		switch event.Action {
+		case SuspendAction:
+			suspension := Suspension{
+				ID:           event.ID,
+				StudentEmail: event.StudentEmail,
+				Reason:       event.Reason,
+				SuspendedBy:  event.Actor,
+				StartsAt:     event.CreatedAt,
+				EndsAt:       event.EndsAt,
+				CreatedAt:    event.CreatedAt,
+			}
+			if event.StartsAt != nil {
+				suspension.StartsAt = *event.StartsAt
+			}
+			suspensions = append(suspensions, suspension)
+		case UnSuspendAction:
+			liftedAt := event.CreatedAt
+			for i := range suspensions {
+				suspension := &suspensions[i]
+				if suspension.LiftedAt != nil || (suspension.EndsAt != nil && !suspension.EndsAt.After(liftedAt)) {
+					continue
+				}
+				suspension.LiftedAt = &liftedAt
+				suspension.LiftedBy = event.Actor
+			}
+		case ExpireAction:
+			for i := range suspensions {
+				suspension := &suspensions[i]
+				if event.SuspensionID == nil || suspension.ID != *event.SuspensionID {
+					continue
+				}
+				if suspension.LiftedAt == nil && suspension.EndsAt != nil {
+					liftedAt := *suspension.EndsAt
+					suspension.LiftedAt = &liftedAt
+					suspension.LiftedBy = event.Actor
+				}
+			}
+		}
+	}
+	return suspensions
+}
+
+// Replays the events of several students (ordered by ID) and returns the emails of the students suspended
+// at the given time, ordered by email
+func SuspendedStudents(events []SuspensionEvent, at time.Time) []string {
+	students := []string{}
+	for student := range ActiveSuspensionsByStudent(events, at) {
+		students = append(students, student)
+	}
+	sort.Strings(students)
+	return students
+}
+
+// Replays the events of several students (ordered by ID) and returns the suspensions applying at the given time,
+// keyed by email. The students who are not suspended are left out.
+func ActiveSuspensionsByStudent(events []SuspensionEvent, at time.Time) map[string]*Suspension {
+	byStudent := make(map[string][]SuspensionEvent)
+	for _, event := range events {
+		byStudent[event.StudentEmail] = append(byStudent[event.StudentEmail], event)
+	}
+
+	active := make(map[string]*Suspension)
+	for student, studentEvents := range byStudent {
+		if suspension := ActiveSuspension(DeriveSuspensions(studentEvents), at); suspension != nil {
+			active[student] = suspension
+		}
+	}
+	return active
+}
+
+// Returns the suspension applying at the given time, nil if there is none
+func ActiveSuspension(suspensions []Suspension, at time.Time) *Suspension {
+	var active *Suspension
+	for i := range suspensions {
+		suspension := &suspensions[i]
+		if !suspension.ActiveAt(at) {
+			continue
+		}
+		if active == nil || suspension.StartsAt.After(active.StartsAt) {
+			active = suspension
+		}
+	}
+	return active
+}
+
+// Statuses of a notification
+const (
+	NotificationScheduled = "scheduled"
+	NotificationSent      = "sent"
+	NotificationCancelled = "cancelled"
+)
+
+// Notification sent by a teacher along with the students it was resolved to.
+// The recipients of a scheduled notification are resolved when it is sent.
+type Notification struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	TeacherEmail string     `json:"teacher"`
+	Text         string     `json:"notification"`
+	Status       string     `json:"status"`
+	SendAt       *time.Time `json:"send_at"` // Only set for scheduled notifications
+	SentAt       *time.Time `json:"sent_at"`
+	Recipients   []string   `json:"recipients" gorm:"-"` // Stored in notification_recipients
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Row of the notification_recipients table
+type NotificationRecipient struct {
+	NotificationID string `gorm:"primaryKey"`
+	StudentEmail   string `gorm:"primaryKey"`
+	Position       int    // Keeps the recipients in the order they were resolved
+}
+
+// Filters used when listing notifications, empty fields are ignored
+type NotificationFilter struct {
+	TeacherEmail string
+	Status       string
+	From         *time.Time // Inclusive
+	To           *time.Time // Exclusive
+}
+
+// Statuses of a delivery
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// Delivery of a notification to one recipient
+type Delivery struct {
+	ID             uint       `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	NotificationID string     `json:"notification_id" gorm:"not null"`
+	StudentEmail   string     `json:"student_email" gorm:"not null"`
+	Channel        string     `json:"channel"` // Channel of the last attempt
+	Status         string     `json:"status" gorm:"not null"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"last_error,omitempty"`
+	SentAt         *time.Time `json:"sent_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Statuses of a background job
+const (
+	JobQueued    = "queued"    // Waiting for RunAt, including the jobs waiting to be retried
+	JobRunning   = "running"   // Claimed by a worker
+	JobSucceeded = "succeeded" // Done
+	JobDead      = "dead"      // Failed MaxAttempts times, only retried manually
+)
+
+// Background job stored in the jobs table, claimed by the workers with FOR UPDATE SKIP LOCKED
+type Job struct {
+	ID          uint       `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	Kind        string     `json:"kind" gorm:"not null"`
+	Payload     string     `json:"payload"` // JSON encoded arguments
+	Status      string     `json:"status" gorm:"not null"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       time.Time  `json:"run_at"`
+	LockedAt    *time.Time `json:"locked_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// API key a teacher authenticates with, only the SHA-256 hash of the key is stored
+type APIKey struct {
+	ID           uint   `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	TeacherEmail string `json:"teacher" gorm:"not null"`
+	Name         string `json:"name"`
+	// First characters of the key, to tell the keys apart
+	Prefix    string     `json:"prefix" gorm:"not null"`
+	Hash      string     `json:"-" gorm:"unique;not null"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// Session of a teacher who logged in with their password, kept server-side so that it can be revoked.
+// Only the SHA-256 hashes of its tokens are stored.
+type Session struct {
+	ID           string `json:"id" gorm:"primary_key"`
+	TeacherEmail string `json:"teacher" gorm:"not null"`
+	AccessHash   string `json:"-" gorm:"unique;not null"`
+	RefreshHash  string `json:"-" gorm:"unique;not null"`
+	// The access token must be refreshed after this time
+	AccessExpiresAt time.Time `json:"access_expires_at"`
+	// The session cannot be refreshed after this time
+	ExpiresAt time.Time `json:"expires_at"`
+	// When the teacher passed the two-factor authentication during the session, nil if they did not
+	TwoFactorAt *time.Time `json:"two_factor_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// Single use token letting a teacher set their password, only its SHA-256 hash is stored
+type PasswordReset struct {
+	Hash         string     `json:"-" gorm:"primary_key"`
+	TeacherEmail string     `json:"teacher" gorm:"not null"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UsedAt       *time.Time `json:"used_at"`
+}
+
+// Single use code a teacher passes the two-factor authentication with when they cannot use their TOTP app,
+// only its SHA-256 hash is stored
+type RecoveryCode struct {
+	ID           uint       `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	TeacherEmail string     `json:"teacher" gorm:"not null"`
+	Hash         string     `json:"-" gorm:"unique;not null"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UsedAt       *time.Time `json:"used_at"`
+}
+
+// Entry of the append-only audit log, recorded by every mutating API call
+type AuditEntry struct {
+	ID uint `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	// Email of the authenticated teacher, or of the teacher logging in
+	Actor string `json:"actor" gorm:"not null"`
+	// e.g. students.register, see the handlers
+	Action string `json:"action" gorm:"not null"`
+	// What the action changed, e.g. the email of the student suspended or the ID of a notification
+	Target    string `json:"target"`
+	RequestID string `json:"request_id"`
+	// State of the target before and after the action, null if it did not exist
+	Before    json.RawMessage `json:"before" gorm:"serializer:json"`
+	After     json.RawMessage `json:"after" gorm:"serializer:json"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Filters used when listing audit entries, empty fields are ignored
+type AuditFilter struct {
+	Actor     string
+	Action    string
+	Target    string
+	RequestID string
+	From      *time.Time // Inclusive
+	To        *time.Time // Exclusive
 }
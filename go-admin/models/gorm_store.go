@@ -0,0 +1,1185 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store implementation backed by a GORM (Postgres) connection
+type GormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Translates gorm's errors into the Store errors
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		// The teacher or student referenced does not exist
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *GormStore) CreateTeacher(teacher *Teacher) error {
+	if teacher.Role == "" {
+		teacher.Role = RoleTeacher
+	}
+	return translateError(s.db.Create(teacher).Error)
+}
+
+func (s *GormStore) GetTeacher(email string) (*Teacher, error) {
+	var teacher Teacher
+	if err := s.db.Where("email = ?", email).First(&teacher).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &teacher, nil
+}
+
+func (s *GormStore) ListTeachers(filter TeacherFilter, page *Page) ([]Teacher, *Cursor, error) {
+	query := s.db
+	if filter.NamePrefix != "" {
+		query = query.Where("lower(name) LIKE ?", likePrefix(filter.NamePrefix))
+	}
+	query, err := teacherOrder.apply(query, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	teachers := []Teacher{}
+	if err := query.Find(&teachers).Error; err != nil {
+		return nil, nil, err
+	}
+	teachers, next := teacherOrder.trim(teachers, page)
+	return teachers, next, nil
+}
+
+func (s *GormStore) UpdateTeacherName(email string, name string) (*Teacher, error) {
+	result := s.db.Model(&Teacher{}).Where("email = ?", email).Update("name", name)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return s.GetTeacher(email)
+}
+
+func (s *GormStore) UpdateTeacherRole(email string, role string) (*Teacher, error) {
+	result := s.db.Model(&Teacher{}).Where("email = ?", email).Update("role", role)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return s.GetTeacher(email)
+}
+
+// The class_teachers rows are removed by the ON DELETE CASCADE foreign key,
+// the ON DELETE RESTRICT one of notifications keeps the teachers who sent some
+func (s *GormStore) DeleteTeacher(email string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("code = ?", TeacherClassCode(email)).Delete(&Class{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("email = ?", email).Delete(&Teacher{})
+		if errors.Is(result.Error, gorm.ErrForeignKeyViolated) {
+			return ErrReferenced
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) CreateStudent(student *Student) error {
+	return translateError(s.db.Create(student).Error)
+}
+
+func (s *GormStore) GetStudent(email string) (*Student, error) {
+	var student Student
+	if err := s.db.Where("email = ?", email).First(&student).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &student, nil
+}
+
+func (s *GormStore) ListStudents(filter StudentFilter, page *Page) ([]Student, *Cursor, error) {
+	students := []Student{}
+	query := s.db
+	if filter.NamePrefix != "" {
+		query = query.Where("lower(name) LIKE ?", likePrefix(filter.NamePrefix))
+	}
+	if filter.Suspended != nil {
+		suspended, err := s.ListSuspendedStudents(filter.SuspendedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case *filter.Suspended && len(suspended) == 0:
+			return students, nil, nil
+		case *filter.Suspended:
+			query = query.Where("email IN ?", suspended)
+		case len(suspended) > 0:
+			query = query.Where("email NOT IN ?", suspended)
+		}
+	}
+	query, err := studentOrder.apply(query, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := query.Find(&students).Error; err != nil {
+		return nil, nil, err
+	}
+	students, next := studentOrder.trim(students, page)
+	return students, next, nil
+}
+
+func (s *GormStore) UpdateStudent(email string, changes StudentChanges) (*Student, error) {
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if changes.Name != nil {
+		updates["name"] = *changes.Name
+	}
+	if changes.Cohort != nil {
+		updates["cohort"] = *changes.Cohort
+	}
+	result := s.db.Model(&Student{}).Where("email = ?", email).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return s.GetStudent(email)
+}
+
+// The enrollments rows are removed by the ON DELETE CASCADE foreign key,
+// the ON DELETE RESTRICT one of suspension_events keeps the students who have a history
+func (s *GormStore) DeleteStudent(email string) error {
+	result := s.db.Where("email = ?", email).Delete(&Student{})
+	if errors.Is(result.Error, gorm.ErrForeignKeyViolated) {
+		return ErrReferenced
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) GetCohortStudents(cohort string) ([]string, error) {
+	students := []string{}
+	err := s.db.Model(&Student{}).
+		Where("cohort <> '' AND lower(cohort) = lower(?)", cohort).
+		Order("email").
+		Pluck("email", &students).Error
+	return students, err
+}
+
+func (s *GormStore) ExistingStudents(studentEmails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	emails := uniqueSorted(studentEmails)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	found := []string{}
+	if err := s.db.Model(&Student{}).Where("email IN ?", emails).Pluck("email", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, email := range found {
+		existing[email] = true
+	}
+	return existing, nil
+}
+
+func (s *GormStore) CreateTerm(term *Term) error {
+	return translateError(s.db.Create(term).Error)
+}
+
+func (s *GormStore) GetTerm(name string) (*Term, error) {
+	var term Term
+	if err := s.db.Where("name = ?", name).First(&term).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &term, nil
+}
+
+func (s *GormStore) GetCurrentTerm() (*Term, error) {
+	var term Term
+	if err := s.db.Where("is_current").First(&term).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &term, nil
+}
+
+func (s *GormStore) ListTerms(page *Page) ([]Term, *Cursor, error) {
+	query, err := termOrder.apply(s.db, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	terms := []Term{}
+	if err := query.Find(&terms).Error; err != nil {
+		return nil, nil, err
+	}
+	terms, next := termOrder.trim(terms, page)
+	return terms, next, nil
+}
+
+func (s *GormStore) RolloverTerm(term *Term, next *Term, carryForward []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&Term{}).Where("id = ?", term.ID).Updates(map[string]interface{}{
+			"is_current":  false,
+			"archived_at": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		next.Current = true
+		if err := tx.Create(next).Error; err != nil {
+			return translateError(err)
+		}
+
+		if lowered := uniqueSorted(lowerAll(carryForward)); len(lowered) > 0 {
+			err := tx.Exec(`INSERT INTO enrollments (term_id, class_id, student_email, created_at)
+				SELECT ?, e.class_id, e.student_email, ?
+				FROM enrollments e
+				JOIN classes c ON c.id = e.class_id
+				WHERE e.term_id = ? AND lower(c.code) IN ?`, next.ID, now, term.ID, lowered).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		term.Current = false
+		term.ArchivedAt = &now
+		return nil
+	})
+}
+
+// Creates the class rows and its class_teachers rows
+func createClass(tx *gorm.DB, class *Class) error {
+	if err := tx.Create(class).Error; err != nil {
+		return translateError(err)
+	}
+	return createClassTeachers(tx, class)
+}
+
+func createClassTeachers(tx *gorm.DB, class *Class) error {
+	class.Teachers = uniqueSorted(class.Teachers)
+	if len(class.Teachers) == 0 {
+		return nil
+	}
+	rows := []ClassTeacher{}
+	for _, teacher := range class.Teachers {
+		rows = append(rows, ClassTeacher{ClassID: class.ID, TeacherEmail: teacher})
+	}
+	return translateError(tx.Create(&rows).Error)
+}
+
+// Fills in the teachers of the classes
+func loadClassTeachers(db *gorm.DB, classes []Class) error {
+	if len(classes) == 0 {
+		return nil
+	}
+	ids := []uint{}
+	for i := range classes {
+		ids = append(ids, classes[i].ID)
+		classes[i].Teachers = []string{}
+	}
+	var rows []ClassTeacher
+	if err := db.Where("class_id IN ?", ids).Order("teacher_email").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for i := range classes {
+			if classes[i].ID == row.ClassID {
+				classes[i].Teachers = append(classes[i].Teachers, row.TeacherEmail)
+			}
+		}
+	}
+	return nil
+}
+
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Matches the class code case insensitively (classes_code_idx is on lower(code))
+func whereCode(db *gorm.DB, code string) *gorm.DB {
+	return db.Where("lower(code) = lower(?)", code)
+}
+
+// Teacher emails which are not in the teachers table are reported as ErrNotFound
+func (s *GormStore) CreateClass(class *Class) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return createClass(tx, class)
+	})
+}
+
+func (s *GormStore) GetClass(code string) (*Class, error) {
+	var class Class
+	if err := whereCode(s.db, code).First(&class).Error; err != nil {
+		return nil, translateError(err)
+	}
+	classes := []Class{class}
+	if err := loadClassTeachers(s.db, classes); err != nil {
+		return nil, err
+	}
+	return &classes[0], nil
+}
+
+func (s *GormStore) ListClasses(filter ClassFilter, page *Page) ([]Class, *Cursor, error) {
+	query := s.db
+	if filter.TeacherEmail != "" {
+		query = query.Where("id IN (?)", s.db.Model(&ClassTeacher{}).Select("class_id").Where("teacher_email = ?", filter.TeacherEmail))
+	}
+	if filter.NamePrefix != "" {
+		query = query.Where("lower(name) LIKE ?", likePrefix(filter.NamePrefix))
+	}
+	query, err := classOrder.apply(query, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	classes := []Class{}
+	if err := query.Find(&classes).Error; err != nil {
+		return nil, nil, err
+	}
+	classes, next := classOrder.trim(classes, page)
+	return classes, next, loadClassTeachers(s.db, classes)
+}
+
+func (s *GormStore) UpdateClass(class *Class) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Class{}).Where("id = ?", class.ID).Updates(map[string]interface{}{
+			"name":       class.Name,
+			"updated_at": time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("class_id = ?", class.ID).Delete(&ClassTeacher{}).Error; err != nil {
+			return err
+		}
+		return createClassTeachers(tx, class)
+	})
+}
+
+// The class_teachers and enrollments rows are removed by the ON DELETE CASCADE foreign keys
+func (s *GormStore) DeleteClass(code string) error {
+	result := whereCode(s.db, code).Delete(&Class{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Enrolls the student only if not enrolled yet
+func enroll(tx *gorm.DB, termID uint, classID uint, studentEmail string) error {
+	enrollment := Enrollment{TermID: termID, ClassID: classID, StudentEmail: studentEmail, CreatedAt: time.Now()}
+	return translateError(tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&enrollment).Error)
+}
+
+func (s *GormStore) CreateEnrollment(termID uint, code string, studentEmail string) error {
+	class, err := s.GetClass(code)
+	if err != nil {
+		return err
+	}
+	return enroll(s.db, termID, class.ID, studentEmail)
+}
+
+func (s *GormStore) DeleteEnrollment(termID uint, code string, studentEmail string) error {
+	result := s.db.
+		Where("term_id = ?", termID).
+		Where("class_id IN (?)", whereCode(s.db.Model(&Class{}), code).Select("id")).
+		Where("student_email = ?", studentEmail).
+		Delete(&Enrollment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) GetClassStudents(termID uint, code string) ([]string, error) {
+	students := []string{}
+	err := s.db.Model(&Enrollment{}).
+		Where("term_id = ?", termID).
+		Where("class_id IN (?)", whereCode(s.db.Model(&Class{}), code).Select("id")).
+		Order("student_email").
+		Pluck("student_email", &students).Error
+	return students, err
+}
+
+func (s *GormStore) GetStudentsClasses(termID uint, codes []string) (map[string][]string, error) {
+	related := make(map[string][]string)
+	lowered := uniqueSorted(lowerAll(codes))
+	if len(lowered) == 0 {
+		return related, nil
+	}
+
+	// Students enrolled in at least one of the classes, as a subquery
+	students := s.db.Model(&Enrollment{}).Select("enrollments.student_email").
+		Joins("JOIN classes ON classes.id = enrollments.class_id").
+		Where("enrollments.term_id = ? AND lower(classes.code) IN ?", termID, lowered)
+
+	var rows []struct {
+		StudentEmail string
+		Code         string
+	}
+	err := s.db.Model(&Enrollment{}).Select("enrollments.student_email, classes.code").
+		Joins("JOIN classes ON classes.id = enrollments.class_id").
+		Where("enrollments.term_id = ? AND enrollments.student_email IN (?)", termID, students).
+		Order("enrollments.student_email, classes.code").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		related[row.StudentEmail] = append(related[row.StudentEmail], row.Code)
+	}
+	return related, nil
+}
+
+func lowerAll(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		result = append(result, strings.ToLower(value))
+	}
+	return result
+}
+
+func (s *GormStore) GetStudentClasses(termID uint, studentEmail string) ([]string, error) {
+	codes := []string{}
+	err := s.db.Model(&Class{}).
+		Joins("JOIN enrollments ON enrollments.class_id = classes.id").
+		Where("enrollments.term_id = ? AND enrollments.student_email = ?", termID, studentEmail).
+		Order("classes.code").
+		Pluck("classes.code", &codes).Error
+	return codes, err
+}
+
+// Ids of the classes taught by the teacher, as a subquery
+func (s *GormStore) teacherClassIDs(teacherEmail string) *gorm.DB {
+	return s.db.Model(&ClassTeacher{}).Select("class_id").Where("teacher_email = ?", teacherEmail)
+}
+
+func (s *GormStore) CreateRegistry(termID uint, teacherEmail string, studentEmail string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Search for the teacher's own class, if it does not exist, we create it
+		var class Class
+		err := whereCode(tx, TeacherClassCode(teacherEmail)).First(&class).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			class = Class{Code: TeacherClassCode(teacherEmail), Name: teacherEmail, Teachers: []string{teacherEmail}}
+			err = createClass(tx, &class)
+		}
+		if err != nil {
+			return translateError(err)
+		}
+		return enroll(tx, termID, class.ID, studentEmail)
+	})
+}
+
+func (s *GormStore) GetRegisteredStudents(termID uint, teacherEmail string) ([]string, error) {
+	students := []string{}
+	err := s.db.Model(&Enrollment{}).Select("student_email").
+		Where("term_id = ? AND class_id IN (?)", termID, s.teacherClassIDs(teacherEmail)).
+		Group("student_email").
+		Order("MIN(created_at), student_email").
+		Pluck("student_email", &students).Error
+	return students, err
+}
+
+func (s *GormStore) DeleteRegistry(termID uint, teacherEmail string, studentEmail string) error {
+	result := s.db.
+		Where("term_id = ? AND class_id IN (?)", termID, s.teacherClassIDs(teacherEmail)).
+		Where("student_email = ?", studentEmail).
+		Delete(&Enrollment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) GetStudentTeachers(termID uint, studentEmail string) ([]string, error) {
+	teachers := []string{}
+	err := s.db.Model(&ClassTeacher{}).Distinct("class_teachers.teacher_email").
+		Joins("JOIN enrollments ON enrollments.class_id = class_teachers.class_id").
+		Where("enrollments.term_id = ? AND enrollments.student_email = ?", termID, studentEmail).
+		Order("class_teachers.teacher_email").
+		Pluck("class_teachers.teacher_email", &teachers).Error
+	return teachers, err
+}
+
+func (s *GormStore) GetStudentsTeachers(termID uint, teacherEmails []string) (map[string][]string, error) {
+	related := make(map[string][]string)
+	if len(teacherEmails) == 0 {
+		return related, nil
+	}
+
+	// Students registered under at least one of the teachers, as a subquery
+	students := s.db.Model(&Enrollment{}).Select("enrollments.student_email").
+		Joins("JOIN class_teachers ON class_teachers.class_id = enrollments.class_id").
+		Where("enrollments.term_id = ? AND class_teachers.teacher_email IN ?", termID, teacherEmails)
+
+	var rows []struct {
+		StudentEmail string
+		TeacherEmail string
+	}
+	err := s.db.Model(&Enrollment{}).Distinct("enrollments.student_email", "class_teachers.teacher_email").
+		Joins("JOIN class_teachers ON class_teachers.class_id = enrollments.class_id").
+		Where("enrollments.term_id = ? AND enrollments.student_email IN (?)", termID, students).
+		Order("enrollments.student_email, class_teachers.teacher_email").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		related[row.StudentEmail] = append(related[row.StudentEmail], row.TeacherEmail)
+	}
+	return related, nil
+}
+
+func (s *GormStore) CreateSuspensionEvent(event *SuspensionEvent) error {
+	return translateError(s.db.Create(event).Error)
+}
+
+func (s *GormStore) ListSuspensionEvents(studentEmail string) ([]SuspensionEvent, error) {
+	events := []SuspensionEvent{}
+	err := s.db.Where("student_email = ?", studentEmail).Order("id").Find(&events).Error
+	return events, err
+}
+
+func (s *GormStore) GetActiveSuspension(studentEmail string, at time.Time) (*Suspension, error) {
+	events, err := s.ListSuspensionEvents(studentEmail)
+	if err != nil {
+		return nil, err
+	}
+	active := ActiveSuspension(DeriveSuspensions(events), at)
+	if active == nil {
+		return nil, ErrNotFound
+	}
+	return active, nil
+}
+
+func (s *GormStore) ActiveSuspensions(studentEmails []string, at time.Time) (map[string]*Suspension, error) {
+	emails := uniqueSorted(studentEmails)
+	if len(emails) == 0 {
+		return map[string]*Suspension{}, nil
+	}
+	events := []SuspensionEvent{}
+	if err := s.db.Where("student_email IN ?", emails).Order("id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return ActiveSuspensionsByStudent(events, at), nil
+}
+
+func (s *GormStore) ListSuspendedStudents(at time.Time) ([]string, error) {
+	// Only the students with a suspend event can be suspended
+	events := []SuspensionEvent{}
+	err := s.db.Where("student_email IN (?)", s.db.Model(&SuspensionEvent{}).Select("student_email").Where("action = ?", SuspendAction)).
+		Order("id").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return SuspendedStudents(events, at), nil
+}
+
+func (s *GormStore) ExpireSuspensions(at time.Time, actor string) (int, error) {
+	// Suspensions which ended, were not lifted by a later unsuspend event before their end and are not expired yet
+	result := s.db.Exec(`INSERT INTO suspension_events (student_email, action, actor, suspension_id, created_at)
+		SELECT s.student_email, ?, ?, s.id, ?
+		FROM suspension_events s
+		WHERE s.action = ? AND s.ends_at <= ?
+		AND NOT EXISTS (
+			SELECT 1 FROM suspension_events e WHERE e.action = ? AND e.suspension_id = s.id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM suspension_events u
+			WHERE u.action = ? AND u.student_email = s.student_email AND u.id > s.id AND u.created_at < s.ends_at
+		)
+		ORDER BY s.id`,
+		ExpireAction, actor, at, SuspendAction, at, ExpireAction, UnSuspendAction)
+	return int(result.RowsAffected), result.Error
+}
+
+func (s *GormStore) CreateNotification(notification *Notification, jobs ...*Job) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(notification).Error; err != nil {
+			return translateError(err)
+		}
+		if err := createRecipients(tx, notification); err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			if err := tx.Create(job).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) UpdateNotification(notification *Notification) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Notification{}).Where("id = ?", notification.ID).Updates(map[string]interface{}{
+			"status":  notification.Status,
+			"send_at": notification.SendAt,
+			"sent_at": notification.SentAt,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("notification_id = ?", notification.ID).Delete(&NotificationRecipient{}).Error; err != nil {
+			return err
+		}
+		return createRecipients(tx, notification)
+	})
+}
+
+func createRecipients(tx *gorm.DB, notification *Notification) error {
+	if len(notification.Recipients) == 0 {
+		return nil
+	}
+	recipients := []NotificationRecipient{}
+	for i, student := range notification.Recipients {
+		recipients = append(recipients, NotificationRecipient{
+			NotificationID: notification.ID,
+			StudentEmail:   student,
+			Position:       i,
+		})
+	}
+	return tx.Create(&recipients).Error
+}
+
+func (s *GormStore) GetNotification(id string) (*Notification, error) {
+	var notification Notification
+	if err := s.db.Where("id = ?", id).First(&notification).Error; err != nil {
+		return nil, translateError(err)
+	}
+	notifications := []Notification{notification}
+	if err := s.loadRecipients(notifications); err != nil {
+		return nil, err
+	}
+	return &notifications[0], nil
+}
+
+func (s *GormStore) ListNotifications(filter NotificationFilter, page *Page) ([]Notification, *Cursor, error) {
+	query := s.db
+	if filter.TeacherEmail != "" {
+		query = query.Where("teacher_email = ?", filter.TeacherEmail)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	query, err := notificationOrder.apply(query, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifications := []Notification{}
+	if err := query.Find(&notifications).Error; err != nil {
+		return nil, nil, err
+	}
+	// Only the recipients of the notifications of the page are loaded
+	notifications, next := notificationOrder.trim(notifications, page)
+	return notifications, next, s.loadRecipients(notifications)
+}
+
+// Fills in the recipients of the given notifications
+func (s *GormStore) loadRecipients(notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	ids := []string{}
+	byID := make(map[string]*Notification)
+	for i := range notifications {
+		notifications[i].Recipients = []string{}
+		ids = append(ids, notifications[i].ID)
+		byID[notifications[i].ID] = &notifications[i]
+	}
+
+	var recipients []NotificationRecipient
+	err := s.db.Where("notification_id IN ?", ids).Order("notification_id, position").Find(&recipients).Error
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		notification := byID[recipient.NotificationID]
+		notification.Recipients = append(notification.Recipients, recipient.StudentEmail)
+	}
+	return nil
+}
+
+func (s *GormStore) CreateDeliveries(deliveries []Delivery, newJob func(delivery Delivery) (*Job, error)) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&deliveries).Error; err != nil {
+			return translateError(err)
+		}
+		for _, delivery := range deliveries {
+			job, err := newJob(delivery)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(job).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) GetDelivery(id uint) (*Delivery, error) {
+	var delivery Delivery
+	if err := s.db.First(&delivery, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &delivery, nil
+}
+
+func (s *GormStore) ListNotificationDeliveries(notificationID string, page *Page) ([]Delivery, *Cursor, error) {
+	query, err := deliveryOrder.apply(s.db.Where("notification_id = ?", notificationID), page)
+	if err != nil {
+		return nil, nil, err
+	}
+	deliveries := []Delivery{}
+	if err := query.Find(&deliveries).Error; err != nil {
+		return nil, nil, err
+	}
+	deliveries, next := deliveryOrder.trim(deliveries, page)
+	return deliveries, next, nil
+}
+
+func (s *GormStore) UpdateDelivery(delivery *Delivery) error {
+	return translateError(s.db.Save(delivery).Error)
+}
+
+func (s *GormStore) CreateJob(job *Job) error {
+	return translateError(s.db.Create(job).Error)
+}
+
+func (s *GormStore) GetJob(id uint) (*Job, error) {
+	var job Job
+	if err := s.db.First(&job, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &job, nil
+}
+
+func (s *GormStore) ClaimJob(now time.Time) (*Job, error) {
+	var job Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Rows locked by other workers are skipped instead of waited on
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", JobQueued, now).
+			Order("run_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+		job.Status = JobRunning
+		job.Attempts++
+		job.LockedAt = &now
+		return tx.Save(&job).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &job, nil
+}
+
+func (s *GormStore) UpdateJob(job *Job) error {
+	return translateError(s.db.Save(job).Error)
+}
+
+func (s *GormStore) ListJobs(status string, page *Page) ([]Job, *Cursor, error) {
+	query := s.db
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query, err := jobOrder.apply(query, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs := []Job{}
+	if err := query.Find(&jobs).Error; err != nil {
+		return nil, nil, err
+	}
+	jobs, next := jobOrder.trim(jobs, page)
+	return jobs, next, nil
+}
+
+func (s *GormStore) RequeueStaleJobs(lockedBefore time.Time) (int, error) {
+	result := s.db.Model(&Job{}).
+		Where("status = ? AND locked_at < ?", JobRunning, lockedBefore).
+		Updates(map[string]interface{}{"status": JobQueued, "locked_at": nil})
+	return int(result.RowsAffected), result.Error
+}
+
+func (s *GormStore) RetryJob(id uint, runAt time.Time) (*Job, error) {
+	var job Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Conditional so that concurrent retries (or a worker updating the job) do not overwrite each other
+		result := tx.Model(&Job{}).
+			Where("id = ? AND status = ?", id, JobDead).
+			Updates(map[string]interface{}{"status": JobQueued, "attempts": 0, "run_at": runAt, "locked_at": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.First(&job, id).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &job, nil
+}
+
+func (s *GormStore) CreateAPIKey(key *APIKey) error {
+	return translateError(s.db.Create(key).Error)
+}
+
+func (s *GormStore) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	var key APIKey
+	if err := s.db.Where("hash = ?", hash).First(&key).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (s *GormStore) ListAPIKeys(teacherEmail string, page *Page) ([]APIKey, *Cursor, error) {
+	query, err := apiKeyOrder.apply(s.db.Where("teacher_email = ?", teacherEmail), page)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := []APIKey{}
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, nil, err
+	}
+	keys, next := apiKeyOrder.trim(keys, page)
+	return keys, next, nil
+}
+
+func (s *GormStore) RevokeAPIKey(teacherEmail string, id uint, at time.Time) error {
+	result := s.db.Model(&APIKey{}).
+		Where("id = ? AND teacher_email = ? AND revoked_at IS NULL", id, teacherEmail).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) CreateSession(session *Session) error {
+	return translateError(s.db.Create(session).Error)
+}
+
+func (s *GormStore) GetSessionByAccessHash(hash string) (*Session, error) {
+	var session Session
+	if err := s.db.Where("access_hash = ?", hash).First(&session).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &session, nil
+}
+
+func (s *GormStore) GetSessionByRefreshHash(hash string) (*Session, error) {
+	var session Session
+	if err := s.db.Where("refresh_hash = ?", hash).First(&session).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &session, nil
+}
+
+func (s *GormStore) ListSessions(teacherEmail string, page *Page) ([]Session, *Cursor, error) {
+	query, err := sessionOrder.apply(s.db.Where("teacher_email = ?", teacherEmail), page)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessions := []Session{}
+	if err := query.Find(&sessions).Error; err != nil {
+		return nil, nil, err
+	}
+	sessions, next := sessionOrder.trim(sessions, page)
+	return sessions, next, nil
+}
+
+func (s *GormStore) RotateSession(session *Session, previousRefreshHash string) error {
+	result := s.db.Model(&Session{}).
+		Where("id = ? AND refresh_hash = ? AND revoked_at IS NULL", session.ID, previousRefreshHash).
+		Updates(map[string]interface{}{
+			"access_hash":       session.AccessHash,
+			"refresh_hash":      session.RefreshHash,
+			"access_expires_at": session.AccessExpiresAt,
+			"updated_at":        time.Now(),
+		})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return s.db.Where("id = ?", session.ID).First(session).Error
+}
+
+func (s *GormStore) RevokeSession(teacherEmail string, id string, at time.Time) error {
+	result := s.db.Model(&Session{}).
+		Where("id = ? AND teacher_email = ? AND revoked_at IS NULL", id, teacherEmail).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) VerifySessionTwoFactor(id string, at time.Time) error {
+	result := s.db.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"two_factor_at": at, "updated_at": at})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) CreatePasswordReset(reset *PasswordReset) error {
+	return translateError(s.db.Create(reset).Error)
+}
+
+func (s *GormStore) ResetPassword(hash string, passwordHash string, at time.Time) (*PasswordReset, error) {
+	var reset PasswordReset
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Locks the reset so that it cannot be used twice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ? AND used_at IS NULL AND expires_at > ?", hash, at).
+			First(&reset).Error
+		if err != nil {
+			return translateError(err)
+		}
+		if err := tx.Model(&reset).Update("used_at", at).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&Teacher{}).Where("email = ?", reset.TeacherEmail).Update("password_hash", passwordHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Model(&Session{}).
+			Where("teacher_email = ? AND revoked_at IS NULL", reset.TeacherEmail).
+			Update("revoked_at", at).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (s *GormStore) SetTOTPSecret(teacherEmail string, secret string) error {
+	teacher, err := s.GetTeacher(teacherEmail)
+	if err != nil {
+		return err
+	}
+	result := s.db.Model(&Teacher{}).
+		Where("email = ? AND NOT totp_enabled", teacher.Email).
+		Update("totp_secret", secret)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func (s *GormStore) EnableTOTP(teacherEmail string, step int64, recoveryHashes []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Teacher{}).Where("email = ?", teacherEmail).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		if err := tx.Where("teacher_email = ?", teacherEmail).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(recoveryHashes) == 0 {
+			return nil
+		}
+		codes := []RecoveryCode{}
+		for _, hash := range recoveryHashes {
+			codes = append(codes, RecoveryCode{TeacherEmail: teacherEmail, Hash: hash})
+		}
+		return translateError(tx.Create(&codes).Error)
+	})
+}
+
+func (s *GormStore) UseTOTPStep(teacherEmail string, step int64) error {
+	result := s.db.Model(&Teacher{}).
+		Where("email = ? AND totp_last_step < ?", teacherEmail, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := s.GetTeacher(teacherEmail); err != nil {
+			return err
+		}
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func (s *GormStore) UseRecoveryCode(teacherEmail string, hash string, at time.Time) error {
+	result := s.db.Model(&RecoveryCode{}).
+		Where("teacher_email = ? AND hash = ? AND used_at IS NULL", teacherEmail, hash).
+		Update("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) CountRecoveryCodes(teacherEmail string) (int, error) {
+	var count int64
+	err := s.db.Model(&RecoveryCode{}).Where("teacher_email = ? AND used_at IS NULL", teacherEmail).Count(&count).Error
+	return int(count), err
+}
+
+func (s *GormStore) RecordTwoFactorFailure(teacherEmail string) (int, error) {
+	var teacher Teacher
+	// Incremented in the database so that concurrent failures are all counted
+	result := s.db.Model(&teacher).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "two_factor_failures"}}}).
+		Where("email = ?", teacherEmail).
+		UpdateColumn("two_factor_failures", gorm.Expr("two_factor_failures + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrNotFound
+	}
+	return teacher.TwoFactorFailures, nil
+}
+
+func (s *GormStore) ResetTwoFactorFailures(teacherEmail string, lockedUntil *time.Time) error {
+	result := s.db.Model(&Teacher{}).Where("email = ?", teacherEmail).Updates(map[string]interface{}{
+		"two_factor_failures":     0,
+		"two_factor_locked_until": lockedUntil,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *GormStore) CreateAuditEntry(entry *AuditEntry) error {
+	return s.db.Create(entry).Error
+}
+
+func (s *GormStore) ListAuditEntries(filter AuditFilter, page *Page) ([]AuditEntry, *Cursor, error) {
+	query := s.db
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	query, err := auditOrder.apply(query, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := []AuditEntry{}
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+	entries, next := auditOrder.trim(entries, page)
+	return entries, next, nil
+}
@@ -0,0 +1,1508 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store implementation which keeps every record in memory.
+// Meant for tests and local experiments, data is lost when the process exits.
+type MemoryStore struct {
+	mu               sync.RWMutex
+	nextID           uint
+	teachers         map[string]*Teacher
+	students         map[string]*Student
+	terms            []Term
+	classes          []Class
+	enrollments      []Enrollment
+	suspensionEvents []SuspensionEvent
+	notifications    []Notification
+	deliveries       []Delivery
+	jobs             []Job
+	apiKeys          []APIKey
+	sessions         []Session
+	passwordResets   []PasswordReset
+	recoveryCodes    []RecoveryCode
+	auditEntries     []AuditEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		teachers: make(map[string]*Teacher),
+		students: make(map[string]*Student),
+	}
+}
+
+// Returns the next auto increment id, caller must hold the lock
+func (s *MemoryStore) newID() uint {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *MemoryStore) CreateTeacher(teacher *Teacher) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teachers[teacher.Email]; ok {
+		return ErrDuplicate
+	}
+	now := time.Now()
+	teacher.ID = s.newID()
+	if teacher.Role == "" {
+		teacher.Role = RoleTeacher
+	}
+	teacher.CreatedAt = now
+	teacher.UpdatedAt = now
+	stored := *teacher
+	s.teachers[teacher.Email] = &stored
+	return nil
+}
+
+func (s *MemoryStore) GetTeacher(email string) (*Teacher, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	teacher, ok := s.teachers[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *teacher
+	return &found, nil
+}
+
+func (s *MemoryStore) ListTeachers(filter TeacherFilter, page *Page) ([]Teacher, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	teachers := []Teacher{}
+	for _, teacher := range s.teachers {
+		if hasPrefixFold(teacher.Name, filter.NamePrefix) {
+			teachers = append(teachers, *teacher)
+		}
+	}
+	sort.Slice(teachers, func(i, j int) bool {
+		return teachers[i].Email < teachers[j].Email
+	})
+	return teacherOrder.paginate(teachers, page)
+}
+
+func (s *MemoryStore) UpdateTeacherName(email string, name string) (*Teacher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teacher, ok := s.teachers[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	teacher.Name = name
+	teacher.UpdatedAt = time.Now()
+	updated := *teacher
+	return &updated, nil
+}
+
+func (s *MemoryStore) UpdateTeacherRole(email string, role string) (*Teacher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teacher, ok := s.teachers[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	teacher.Role = role
+	teacher.UpdatedAt = time.Now()
+	updated := *teacher
+	return &updated, nil
+}
+
+func (s *MemoryStore) DeleteTeacher(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teachers[email]; !ok {
+		return ErrNotFound
+	}
+	for _, notification := range s.notifications {
+		if notification.TeacherEmail == email {
+			return ErrReferenced
+		}
+	}
+	delete(s.teachers, email)
+	if class := s.findClass(TeacherClassCode(email)); class != nil {
+		s.deleteClass(class.ID)
+	}
+	for i := range s.classes {
+		s.classes[i].Teachers = removeString(s.classes[i].Teachers, email)
+	}
+	keys := s.apiKeys[:0]
+	for _, key := range s.apiKeys {
+		if key.TeacherEmail != email {
+			keys = append(keys, key)
+		}
+	}
+	s.apiKeys = keys
+	sessions := s.sessions[:0]
+	for _, session := range s.sessions {
+		if session.TeacherEmail != email {
+			sessions = append(sessions, session)
+		}
+	}
+	s.sessions = sessions
+	resets := s.passwordResets[:0]
+	for _, reset := range s.passwordResets {
+		if reset.TeacherEmail != email {
+			resets = append(resets, reset)
+		}
+	}
+	s.passwordResets = resets
+	codes := s.recoveryCodes[:0]
+	for _, code := range s.recoveryCodes {
+		if code.TeacherEmail != email {
+			codes = append(codes, code)
+		}
+	}
+	s.recoveryCodes = codes
+	return nil
+}
+
+func (s *MemoryStore) CreateStudent(student *Student) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.students[student.Email]; ok {
+		return ErrDuplicate
+	}
+	now := time.Now()
+	student.ID = s.newID()
+	student.CreatedAt = now
+	student.UpdatedAt = now
+	stored := *student
+	s.students[student.Email] = &stored
+	return nil
+}
+
+func (s *MemoryStore) GetStudent(email string) (*Student, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	student, ok := s.students[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *student
+	return &found, nil
+}
+
+func (s *MemoryStore) ListStudents(filter StudentFilter, page *Page) ([]Student, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	suspended := make(map[string]bool)
+	if filter.Suspended != nil {
+		for _, email := range SuspendedStudents(s.suspensionEvents, filter.SuspendedAt) {
+			suspended[email] = true
+		}
+	}
+	students := []Student{}
+	for _, student := range s.students {
+		if !hasPrefixFold(student.Name, filter.NamePrefix) {
+			continue
+		}
+		if filter.Suspended != nil && suspended[student.Email] != *filter.Suspended {
+			continue
+		}
+		students = append(students, *student)
+	}
+	sort.Slice(students, func(i, j int) bool {
+		return students[i].Email < students[j].Email
+	})
+	return studentOrder.paginate(students, page)
+}
+
+func (s *MemoryStore) UpdateStudent(email string, changes StudentChanges) (*Student, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	student, ok := s.students[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if changes.Name != nil {
+		student.Name = *changes.Name
+	}
+	if changes.Cohort != nil {
+		student.Cohort = *changes.Cohort
+	}
+	student.UpdatedAt = time.Now()
+	updated := *student
+	return &updated, nil
+}
+
+func (s *MemoryStore) GetCohortStudents(cohort string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	students := []string{}
+	for _, student := range s.students {
+		if student.Cohort != "" && strings.EqualFold(student.Cohort, cohort) {
+			students = append(students, student.Email)
+		}
+	}
+	sort.Strings(students)
+	return students, nil
+}
+
+func (s *MemoryStore) ExistingStudents(studentEmails []string) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	existing := make(map[string]bool)
+	for _, email := range studentEmails {
+		if _, ok := s.students[email]; ok {
+			existing[email] = true
+		}
+	}
+	return existing, nil
+}
+
+func (s *MemoryStore) DeleteStudent(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.students[email]; !ok {
+		return ErrNotFound
+	}
+	if len(s.studentSuspensionEvents(email)) > 0 {
+		return ErrReferenced
+	}
+	delete(s.students, email)
+	s.deleteEnrollments(func(enrollment Enrollment) bool {
+		return enrollment.StudentEmail == email
+	})
+	return nil
+}
+
+// Returns the term with the given id, caller must hold the lock
+func (s *MemoryStore) findTermByID(id uint) *Term {
+	for i := range s.terms {
+		if s.terms[i].ID == id {
+			return &s.terms[i]
+		}
+	}
+	return nil
+}
+
+// Caller must hold the lock
+func (s *MemoryStore) createTerm(term *Term) error {
+	for _, existing := range s.terms {
+		if existing.Name == term.Name || (existing.Current && term.Current) {
+			return ErrDuplicate
+		}
+	}
+	now := time.Now()
+	term.ID = s.newID()
+	term.CreatedAt = now
+	term.UpdatedAt = now
+	s.terms = append(s.terms, *term)
+	return nil
+}
+
+func (s *MemoryStore) CreateTerm(term *Term) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createTerm(term)
+}
+
+func (s *MemoryStore) GetTerm(name string) (*Term, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, term := range s.terms {
+		if term.Name == name {
+			found := term
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) GetCurrentTerm() (*Term, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, term := range s.terms {
+		if term.Current {
+			found := term
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) ListTerms(page *Page) ([]Term, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return termOrder.paginate(append([]Term{}, s.terms...), page)
+}
+
+func (s *MemoryStore) RolloverTerm(term *Term, next *Term, carryForward []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archived := s.findTermByID(term.ID)
+	if archived == nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	archived.Current = false
+	archived.ArchivedAt = &now
+	archived.UpdatedAt = now
+
+	next.Current = true
+	if err := s.createTerm(next); err != nil {
+		// Leave the term as it was
+		archived.Current = term.Current
+		archived.ArchivedAt = term.ArchivedAt
+		return err
+	}
+	*term = *archived
+
+	carried := make(map[uint]bool)
+	for _, code := range carryForward {
+		if class := s.findClass(code); class != nil {
+			carried[class.ID] = true
+		}
+	}
+	for _, enrollment := range s.enrollments {
+		if enrollment.TermID == term.ID && carried[enrollment.ClassID] {
+			s.enrollments = append(s.enrollments, Enrollment{
+				TermID:       next.ID,
+				ClassID:      enrollment.ClassID,
+				StudentEmail: enrollment.StudentEmail,
+				CreatedAt:    now,
+			})
+		}
+	}
+	return nil
+}
+
+// Removes the enrollments matching the filter, caller must hold the lock
+func (s *MemoryStore) deleteEnrollments(match func(enrollment Enrollment) bool) int {
+	kept := s.enrollments[:0]
+	for _, enrollment := range s.enrollments {
+		if !match(enrollment) {
+			kept = append(kept, enrollment)
+		}
+	}
+	deleted := len(s.enrollments) - len(kept)
+	s.enrollments = kept
+	return deleted
+}
+
+// Returns the class with the given code (case insensitive), caller must hold the lock
+func (s *MemoryStore) findClass(code string) *Class {
+	for i := range s.classes {
+		if strings.EqualFold(s.classes[i].Code, code) {
+			return &s.classes[i]
+		}
+	}
+	return nil
+}
+
+// Deletes the class along with its enrollments, caller must hold the lock
+func (s *MemoryStore) deleteClass(id uint) {
+	kept := s.classes[:0]
+	for _, class := range s.classes {
+		if class.ID != id {
+			kept = append(kept, class)
+		}
+	}
+	s.classes = kept
+	s.deleteEnrollments(func(enrollment Enrollment) bool {
+		return enrollment.ClassID == id
+	})
+}
+
+// Returns a copy of the class, caller must hold the lock
+func copyClass(class *Class) Class {
+	copied := *class
+	copied.Teachers = append([]string{}, class.Teachers...)
+	return copied
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	kept := []string{}
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// Checks that the teachers exist and returns them sorted without duplicates, caller must hold the lock
+func (s *MemoryStore) classTeachers(teachers []string) ([]string, error) {
+	result := []string{}
+	for _, teacher := range teachers {
+		if _, ok := s.teachers[teacher]; !ok {
+			return nil, ErrNotFound
+		}
+		if !containsString(result, teacher) {
+			result = append(result, teacher)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (s *MemoryStore) CreateClass(class *Class) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createClass(class)
+}
+
+// Caller must hold the lock
+func (s *MemoryStore) createClass(class *Class) error {
+	if s.findClass(class.Code) != nil {
+		return ErrDuplicate
+	}
+	teachers, err := s.classTeachers(class.Teachers)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	class.ID = s.newID()
+	class.Teachers = teachers
+	class.CreatedAt = now
+	class.UpdatedAt = now
+	s.classes = append(s.classes, copyClass(class))
+	return nil
+}
+
+func (s *MemoryStore) GetClass(code string) (*Class, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	class := s.findClass(code)
+	if class == nil {
+		return nil, ErrNotFound
+	}
+	found := copyClass(class)
+	return &found, nil
+}
+
+func (s *MemoryStore) ListClasses(filter ClassFilter, page *Page) ([]Class, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	classes := []Class{}
+	for i := range s.classes {
+		class := &s.classes[i]
+		if filter.TeacherEmail != "" && !containsString(class.Teachers, filter.TeacherEmail) {
+			continue
+		}
+		if hasPrefixFold(class.Name, filter.NamePrefix) {
+			classes = append(classes, copyClass(class))
+		}
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return classes[i].Code < classes[j].Code
+	})
+	return classOrder.paginate(classes, page)
+}
+
+func (s *MemoryStore) UpdateClass(class *Class) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.classes {
+		if s.classes[i].ID != class.ID {
+			continue
+		}
+		teachers, err := s.classTeachers(class.Teachers)
+		if err != nil {
+			return err
+		}
+		class.Teachers = teachers
+		class.UpdatedAt = time.Now()
+		s.classes[i].Name = class.Name
+		s.classes[i].Teachers = append([]string{}, teachers...)
+		s.classes[i].UpdatedAt = class.UpdatedAt
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) DeleteClass(code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	class := s.findClass(code)
+	if class == nil {
+		return ErrNotFound
+	}
+	s.deleteClass(class.ID)
+	return nil
+}
+
+func (s *MemoryStore) CreateEnrollment(termID uint, code string, studentEmail string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	class := s.findClass(code)
+	if class == nil {
+		return ErrNotFound
+	}
+	return s.enroll(termID, class.ID, studentEmail)
+}
+
+// Enrolls the student only if not enrolled yet, caller must hold the lock
+func (s *MemoryStore) enroll(termID uint, classID uint, studentEmail string) error {
+	if _, ok := s.students[studentEmail]; !ok {
+		return ErrNotFound
+	}
+	if s.findTermByID(termID) == nil {
+		return ErrNotFound
+	}
+	for _, enrollment := range s.enrollments {
+		if enrollment.TermID == termID && enrollment.ClassID == classID && enrollment.StudentEmail == studentEmail {
+			return nil
+		}
+	}
+	s.enrollments = append(s.enrollments, Enrollment{
+		TermID:       termID,
+		ClassID:      classID,
+		StudentEmail: studentEmail,
+		CreatedAt:    time.Now(),
+	})
+	return nil
+}
+
+func (s *MemoryStore) DeleteEnrollment(termID uint, code string, studentEmail string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	class := s.findClass(code)
+	if class == nil {
+		return ErrNotFound
+	}
+	deleted := s.deleteEnrollments(func(enrollment Enrollment) bool {
+		return enrollment.TermID == termID && enrollment.ClassID == class.ID && enrollment.StudentEmail == studentEmail
+	})
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetClassStudents(termID uint, code string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	students := []string{}
+	class := s.findClass(code)
+	if class == nil {
+		return students, nil
+	}
+	for _, enrollment := range s.enrollments {
+		if enrollment.TermID == termID && enrollment.ClassID == class.ID {
+			students = append(students, enrollment.StudentEmail)
+		}
+	}
+	sort.Strings(students)
+	return students, nil
+}
+
+func (s *MemoryStore) GetStudentsClasses(termID uint, codes []string) (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	related := make(map[string][]string)
+	for student, classes := range s.studentsRelations(termID, func(class Class) []string { return []string{class.Code} }) {
+		for _, code := range codes {
+			if containsFold(classes, code) {
+				related[student] = classes
+				break
+			}
+		}
+	}
+	return related, nil
+}
+
+func (s *MemoryStore) GetStudentClasses(termID uint, studentEmail string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	codes := []string{}
+	for _, enrollment := range s.enrollments {
+		if enrollment.TermID != termID || enrollment.StudentEmail != studentEmail {
+			continue
+		}
+		for _, class := range s.classes {
+			if class.ID == enrollment.ClassID {
+				codes = append(codes, class.Code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+// Returns the ids of the classes taught by the teacher, caller must hold the lock
+func (s *MemoryStore) teacherClassIDs(teacherEmail string) map[uint]bool {
+	ids := make(map[uint]bool)
+	for _, class := range s.classes {
+		if containsString(class.Teachers, teacherEmail) {
+			ids[class.ID] = true
+		}
+	}
+	return ids
+}
+
+func (s *MemoryStore) CreateRegistry(termID uint, teacherEmail string, studentEmail string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teachers[teacherEmail]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.students[studentEmail]; !ok {
+		return ErrNotFound
+	}
+	class := s.findClass(TeacherClassCode(teacherEmail))
+	if class == nil {
+		class = &Class{Code: TeacherClassCode(teacherEmail), Name: teacherEmail, Teachers: []string{teacherEmail}}
+		if err := s.createClass(class); err != nil {
+			return err
+		}
+	}
+	return s.enroll(termID, class.ID, studentEmail)
+}
+
+func (s *MemoryStore) GetRegisteredStudents(termID uint, teacherEmail string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	classIDs := s.teacherClassIDs(teacherEmail)
+	students := []string{}
+	for _, enrollment := range s.enrollments {
+		if enrollment.TermID == termID && classIDs[enrollment.ClassID] && !containsString(students, enrollment.StudentEmail) {
+			students = append(students, enrollment.StudentEmail)
+		}
+	}
+	return students, nil
+}
+
+func (s *MemoryStore) DeleteRegistry(termID uint, teacherEmail string, studentEmail string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	classIDs := s.teacherClassIDs(teacherEmail)
+	deleted := s.deleteEnrollments(func(enrollment Enrollment) bool {
+		return enrollment.TermID == termID && classIDs[enrollment.ClassID] && enrollment.StudentEmail == studentEmail
+	})
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetStudentTeachers(termID uint, studentEmail string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	teachers := []string{}
+	for _, enrollment := range s.enrollments {
+		if enrollment.TermID != termID || enrollment.StudentEmail != studentEmail {
+			continue
+		}
+		for _, class := range s.classes {
+			if class.ID != enrollment.ClassID {
+				continue
+			}
+			for _, teacher := range class.Teachers {
+				if !containsString(teachers, teacher) {
+					teachers = append(teachers, teacher)
+				}
+			}
+		}
+	}
+	sort.Strings(teachers)
+	return teachers, nil
+}
+
+func (s *MemoryStore) GetStudentsTeachers(termID uint, teacherEmails []string) (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	related := make(map[string][]string)
+	for student, teachers := range s.studentsRelations(termID, func(class Class) []string { return class.Teachers }) {
+		for _, teacher := range teacherEmails {
+			if containsString(teachers, teacher) {
+				related[student] = teachers
+				break
+			}
+		}
+	}
+	return related, nil
+}
+
+// Returns the sorted values picked from the classes every student is enrolled in during the term,
+// keyed by student, caller must hold the lock
+func (s *MemoryStore) studentsRelations(termID uint, pick func(class Class) []string) map[string][]string {
+	relations := make(map[string][]string)
+	for _, enrollment := range s.enrollments {
+		if enrollment.TermID != termID {
+			continue
+		}
+		for _, class := range s.classes {
+			if class.ID != enrollment.ClassID {
+				continue
+			}
+			for _, value := range pick(class) {
+				if !containsString(relations[enrollment.StudentEmail], value) {
+					relations[enrollment.StudentEmail] = append(relations[enrollment.StudentEmail], value)
+				}
+			}
+		}
+	}
+	for _, values := range relations {
+		sort.Strings(values)
+	}
+	return relations
+}
+
+func (s *MemoryStore) CreateSuspensionEvent(event *SuspensionEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.students[event.StudentEmail]; !ok {
+		return ErrNotFound
+	}
+	event.ID = s.newID()
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	s.suspensionEvents = append(s.suspensionEvents, *event)
+	return nil
+}
+
+func (s *MemoryStore) ListSuspensionEvents(studentEmail string) ([]SuspensionEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.studentSuspensionEvents(studentEmail), nil
+}
+
+// Caller must hold the lock
+func (s *MemoryStore) studentSuspensionEvents(studentEmail string) []SuspensionEvent {
+	events := []SuspensionEvent{}
+	for _, event := range s.suspensionEvents {
+		if event.StudentEmail == studentEmail {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (s *MemoryStore) GetActiveSuspension(studentEmail string, at time.Time) (*Suspension, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	active := ActiveSuspension(DeriveSuspensions(s.studentSuspensionEvents(studentEmail)), at)
+	if active == nil {
+		return nil, ErrNotFound
+	}
+	return active, nil
+}
+
+func (s *MemoryStore) ActiveSuspensions(studentEmails []string, at time.Time) (map[string]*Suspension, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string]struct{}, len(studentEmails))
+	for _, email := range studentEmails {
+		wanted[email] = struct{}{}
+	}
+	events := []SuspensionEvent{}
+	for _, event := range s.suspensionEvents {
+		if _, ok := wanted[event.StudentEmail]; ok {
+			events = append(events, event)
+		}
+	}
+	return ActiveSuspensionsByStudent(events, at), nil
+}
+
+func (s *MemoryStore) ListSuspendedStudents(at time.Time) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return SuspendedStudents(s.suspensionEvents, at), nil
+}
+
+func (s *MemoryStore) ExpireSuspensions(at time.Time, actor string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for email := range s.students {
+		for _, suspension := range DeriveSuspensions(s.studentSuspensionEvents(email)) {
+			if suspension.LiftedAt != nil || suspension.EndsAt == nil || suspension.EndsAt.After(at) {
+				continue
+			}
+			suspensionID := suspension.ID
+			s.suspensionEvents = append(s.suspensionEvents, SuspensionEvent{
+				ID:           s.newID(),
+				StudentEmail: email,
+				Action:       ExpireAction,
+				Actor:        actor,
+				SuspensionID: &suspensionID,
+				CreatedAt:    at,
+			})
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) CreateNotification(notification *Notification, jobs ...*Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teachers[notification.TeacherEmail]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range s.notifications {
+		if existing.ID == notification.ID {
+			return ErrDuplicate
+		}
+	}
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+	stored := *notification
+	stored.Recipients = append([]string{}, notification.Recipients...)
+	s.notifications = append(s.notifications, stored)
+	for _, job := range jobs {
+		s.createJob(job)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetNotification(id string) (*Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, notification := range s.notifications {
+		if notification.ID == id {
+			found := notification
+			found.Recipients = append([]string{}, notification.Recipients...)
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) UpdateNotification(notification *Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.notifications {
+		stored := &s.notifications[i]
+		if stored.ID == notification.ID {
+			stored.Status = notification.Status
+			stored.SendAt = notification.SendAt
+			stored.SentAt = notification.SentAt
+			stored.Recipients = append([]string{}, notification.Recipients...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) ListNotifications(filter NotificationFilter, page *Page) ([]Notification, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notifications := []Notification{}
+	for _, notification := range s.notifications {
+		if filter.TeacherEmail != "" && notification.TeacherEmail != filter.TeacherEmail {
+			continue
+		}
+		if filter.Status != "" && notification.Status != filter.Status {
+			continue
+		}
+		if filter.From != nil && notification.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !notification.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		found := notification
+		found.Recipients = append([]string{}, notification.Recipients...)
+		notifications = append(notifications, found)
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+	return notificationOrder.paginate(notifications, page)
+}
+
+func (s *MemoryStore) CreateDeliveries(deliveries []Delivery, newJob func(delivery Delivery) (*Job, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	jobs := make([]*Job, len(deliveries))
+	for i := range deliveries {
+		deliveries[i].ID = s.newID()
+		deliveries[i].CreatedAt = now
+		deliveries[i].UpdatedAt = now
+		job, err := newJob(deliveries[i])
+		if err != nil {
+			return err
+		}
+		jobs[i] = job
+	}
+	s.deliveries = append(s.deliveries, deliveries...)
+	for _, job := range jobs {
+		s.createJob(job)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetDelivery(id uint) (*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, delivery := range s.deliveries {
+		if delivery.ID == id {
+			found := delivery
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) ListNotificationDeliveries(notificationID string, page *Page) ([]Delivery, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries := []Delivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.NotificationID == notificationID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveryOrder.paginate(deliveries, page)
+}
+
+func (s *MemoryStore) UpdateDelivery(delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == delivery.ID {
+			delivery.UpdatedAt = time.Now()
+			s.deliveries[i] = *delivery
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) CreateJob(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.createJob(job)
+	return nil
+}
+
+// Same as CreateJob, the caller holds the lock
+func (s *MemoryStore) createJob(job *Job) {
+	now := time.Now()
+	job.ID = s.newID()
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	s.jobs = append(s.jobs, *job)
+}
+
+func (s *MemoryStore) GetJob(id uint) (*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, job := range s.jobs {
+		if job.ID == id {
+			found := job
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) ClaimJob(now time.Time) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *Job
+	for i := range s.jobs {
+		job := &s.jobs[i]
+		if job.Status != JobQueued || job.RunAt.After(now) {
+			continue
+		}
+		if next == nil || job.RunAt.Before(next.RunAt) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, ErrNotFound
+	}
+	next.Status = JobRunning
+	next.Attempts++
+	next.LockedAt = &now
+	next.UpdatedAt = time.Now()
+	claimed := *next
+	return &claimed, nil
+}
+
+func (s *MemoryStore) UpdateJob(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.jobs {
+		if s.jobs[i].ID == job.ID {
+			job.UpdatedAt = time.Now()
+			s.jobs[i] = *job
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) ListJobs(status string, page *Page) ([]Job, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := []Job{}
+	for _, job := range s.jobs {
+		if status == "" || job.Status == status {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobOrder.paginate(jobs, page)
+}
+
+func (s *MemoryStore) RequeueStaleJobs(lockedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for i := range s.jobs {
+		job := &s.jobs[i]
+		if job.Status == JobRunning && job.LockedAt != nil && job.LockedAt.Before(lockedBefore) {
+			job.Status = JobQueued
+			job.LockedAt = nil
+			job.UpdatedAt = time.Now()
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) RetryJob(id uint, runAt time.Time) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.jobs {
+		job := &s.jobs[i]
+		if job.ID == id && job.Status == JobDead {
+			job.Status = JobQueued
+			job.Attempts = 0
+			job.RunAt = runAt
+			job.LockedAt = nil
+			job.UpdatedAt = time.Now()
+			retried := *job
+			return &retried, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) CreateAPIKey(key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teachers[key.TeacherEmail]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range s.apiKeys {
+		if existing.Hash == key.Hash {
+			return ErrDuplicate
+		}
+	}
+	key.ID = s.newID()
+	key.CreatedAt = time.Now()
+	s.apiKeys = append(s.apiKeys, *key)
+	return nil
+}
+
+func (s *MemoryStore) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Hash == hash {
+			copied := key
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) ListAPIKeys(teacherEmail string, page *Page) ([]APIKey, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []APIKey{}
+	for _, key := range s.apiKeys {
+		if key.TeacherEmail == teacherEmail {
+			keys = append(keys, key)
+		}
+	}
+	return apiKeyOrder.paginate(keys, page)
+}
+
+func (s *MemoryStore) RevokeAPIKey(teacherEmail string, id uint, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.apiKeys {
+		key := &s.apiKeys[i]
+		if key.ID == id && key.TeacherEmail == teacherEmail && key.RevokedAt == nil {
+			key.RevokedAt = &at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) CreateSession(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teachers[session.TeacherEmail]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range s.sessions {
+		if existing.ID == session.ID || existing.AccessHash == session.AccessHash || existing.RefreshHash == session.RefreshHash {
+			return ErrDuplicate
+		}
+	}
+	now := time.Now()
+	session.CreatedAt = now
+	session.UpdatedAt = now
+	s.sessions = append(s.sessions, *session)
+	return nil
+}
+
+func (s *MemoryStore) GetSessionByAccessHash(hash string) (*Session, error) {
+	return s.findSession(func(session Session) bool { return session.AccessHash == hash })
+}
+
+func (s *MemoryStore) GetSessionByRefreshHash(hash string) (*Session, error) {
+	return s.findSession(func(session Session) bool { return session.RefreshHash == hash })
+}
+
+func (s *MemoryStore) findSession(match func(session Session) bool) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, session := range s.sessions {
+		if match(session) {
+			copied := session
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) ListSessions(teacherEmail string, page *Page) ([]Session, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := []Session{}
+	for _, session := range s.sessions {
+		if session.TeacherEmail == teacherEmail {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessionOrder.paginate(sessions, page)
+}
+
+func (s *MemoryStore) RotateSession(session *Session, previousRefreshHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		stored := &s.sessions[i]
+		if stored.ID == session.ID && stored.RefreshHash == previousRefreshHash && stored.RevokedAt == nil {
+			stored.AccessHash = session.AccessHash
+			stored.RefreshHash = session.RefreshHash
+			stored.AccessExpiresAt = session.AccessExpiresAt
+			stored.UpdatedAt = time.Now()
+			*session = *stored
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) RevokeSession(teacherEmail string, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		session := &s.sessions[i]
+		if session.ID == id && session.TeacherEmail == teacherEmail && session.RevokedAt == nil {
+			session.RevokedAt = &at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) VerifySessionTwoFactor(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		session := &s.sessions[i]
+		if session.ID == id && session.RevokedAt == nil {
+			session.TwoFactorAt = &at
+			session.UpdatedAt = at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) CreatePasswordReset(reset *PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teachers[reset.TeacherEmail]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range s.passwordResets {
+		if existing.Hash == reset.Hash {
+			return ErrDuplicate
+		}
+	}
+	reset.CreatedAt = time.Now()
+	s.passwordResets = append(s.passwordResets, *reset)
+	return nil
+}
+
+func (s *MemoryStore) ResetPassword(hash string, passwordHash string, at time.Time) (*PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.passwordResets {
+		reset := &s.passwordResets[i]
+		if reset.Hash != hash || reset.UsedAt != nil || !at.Before(reset.ExpiresAt) {
+			continue
+		}
+		teacher, ok := s.teachers[reset.TeacherEmail]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		reset.UsedAt = &at
+		teacher.PasswordHash = passwordHash
+		teacher.UpdatedAt = at
+		for j := range s.sessions {
+			if s.sessions[j].TeacherEmail == reset.TeacherEmail && s.sessions[j].RevokedAt == nil {
+				s.sessions[j].RevokedAt = &at
+			}
+		}
+		used := *reset
+		return &used, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) SetTOTPSecret(teacherEmail string, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teacher, ok := s.teachers[teacherEmail]
+	if !ok {
+		return ErrNotFound
+	}
+	if teacher.TOTPEnabled {
+		return ErrDuplicate
+	}
+	teacher.TOTPSecret = secret
+	teacher.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) EnableTOTP(teacherEmail string, step int64, recoveryHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teacher, ok := s.teachers[teacherEmail]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	teacher.TOTPEnabled = true
+	teacher.TOTPLastStep = step
+	teacher.UpdatedAt = now
+
+	codes := s.recoveryCodes[:0]
+	for _, code := range s.recoveryCodes {
+		if code.TeacherEmail != teacherEmail {
+			codes = append(codes, code)
+		}
+	}
+	for _, hash := range recoveryHashes {
+		codes = append(codes, RecoveryCode{ID: s.newID(), TeacherEmail: teacherEmail, Hash: hash, CreatedAt: now})
+	}
+	s.recoveryCodes = codes
+	return nil
+}
+
+func (s *MemoryStore) UseTOTPStep(teacherEmail string, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teacher, ok := s.teachers[teacherEmail]
+	if !ok {
+		return ErrNotFound
+	}
+	if step <= teacher.TOTPLastStep {
+		return ErrDuplicate
+	}
+	teacher.TOTPLastStep = step
+	return nil
+}
+
+func (s *MemoryStore) UseRecoveryCode(teacherEmail string, hash string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.recoveryCodes {
+		code := &s.recoveryCodes[i]
+		if code.TeacherEmail == teacherEmail && code.Hash == hash && code.UsedAt == nil {
+			code.UsedAt = &at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) CountRecoveryCodes(teacherEmail string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, code := range s.recoveryCodes {
+		if code.TeacherEmail == teacherEmail && code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) RecordTwoFactorFailure(teacherEmail string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teacher, ok := s.teachers[teacherEmail]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	teacher.TwoFactorFailures++
+	return teacher.TwoFactorFailures, nil
+}
+
+func (s *MemoryStore) ResetTwoFactorFailures(teacherEmail string, lockedUntil *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teacher, ok := s.teachers[teacherEmail]
+	if !ok {
+		return ErrNotFound
+	}
+	teacher.TwoFactorFailures = 0
+	teacher.TwoFactorLockedUntil = lockedUntil
+	teacher.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) CreateAuditEntry(entry *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = s.newID()
+	entry.CreatedAt = time.Now()
+	s.auditEntries = append(s.auditEntries, *entry)
+	return nil
+}
+
+func (s *MemoryStore) ListAuditEntries(filter AuditFilter, page *Page) ([]AuditEntry, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []AuditEntry{}
+	for _, entry := range s.auditEntries {
+		if filter.Actor != "" && entry.Actor != filter.Actor {
+			continue
+		}
+		if filter.Action != "" && entry.Action != filter.Action {
+			continue
+		}
+		if filter.Target != "" && entry.Target != filter.Target {
+			continue
+		}
+		if filter.RequestID != "" && entry.RequestID != filter.RequestID {
+			continue
+		}
+		if filter.From != nil && entry.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !entry.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return auditOrder.paginate(entries, page)
+}
+
+// Whether the value starts with the prefix, case insensitive
+func hasPrefixFold(value string, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(value), strings.ToLower(prefix))
+}
@@ -0,0 +1,282 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Page of a list: at most Limit items sorted by Sort (ties broken by their keys) coming after the cursor.
+// The list methods of a Store return every item when given a nil page.
+type Page struct {
+	Sort  string
+	Desc  bool
+	Limit int
+	// Position of the last item of the previous page, nil for the first page
+	After *Cursor
+}
+
+// Position of an item in a sorted list, the sort value and the key of the item
+type Cursor struct {
+	Value string
+	Key   string
+}
+
+// Sort value of a time, fixed width so that values compare as strings
+func SortTime(t time.Time) string {
+	return t.UTC().Format(sortTimeLayout)
+}
+
+const sortTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Sort value of an id, zero padded so that values compare as strings
+func SortID(id uint) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+// Sorts the items held in memory by the page's field then returns the items of the page along with the cursor
+// of the next page, nil if this is the last page. value returns the sort value of an item for a field, key identifies
+// an item. The items are returned as they are if p is nil.
+func Paginate[T any](items []T, p *Page, value func(item T, field string) string, key func(item T) string) ([]T, *Cursor) {
+	if p == nil {
+		return items, nil
+	}
+	position := func(item T) Cursor {
+		return Cursor{Value: value(item, p.Sort), Key: key(item)}
+	}
+	// Whether the item at position a comes before b in the page's order
+	before := func(a, b Cursor) bool {
+		less := a.Value < b.Value || (a.Value == b.Value && a.Key < b.Key)
+		if p.Desc {
+			return !less && a != b
+		}
+		return less
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return before(position(items[i]), position(items[j]))
+	})
+
+	start := 0
+	if p.After != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return before(*p.After, position(items[i]))
+		})
+	}
+	end := min(start+p.Limit, len(items))
+
+	var next *Cursor
+	if end < len(items) {
+		last := position(items[end-1])
+		next = &last
+	}
+	return items[start:end], next
+}
+
+// How the items of a list are sorted, in memory (value and key) and in SQL (columns and keyColumn)
+type listOrder[T any] struct {
+	value func(item T, field string) string
+	key   func(item T) string
+	// SQL expression of each sortable field, sorting the same way as value
+	columns   map[string]string
+	keyColumn string
+	// Whether the key is an id, see SortID
+	numericKey bool
+	// ORDER BY clause used when every item is listed
+	fallback string
+}
+
+// Pages the items held in memory, the cursor is checked the same way as in SQL
+func (o listOrder[T]) paginate(items []T, p *Page) ([]T, *Cursor, error) {
+	if _, _, err := o.cursorArgs(p); err != nil {
+		return nil, nil, err
+	}
+	items, next := Paginate(items, p, o.value, o.key)
+	return items, next, nil
+}
+
+// Values of the page's cursor as compared in SQL: a time for the fields ending with _at, a number for the ids
+func (o listOrder[T]) cursorArgs(p *Page) (interface{}, interface{}, error) {
+	if p == nil || p.After == nil {
+		return nil, nil, nil
+	}
+	var value, key interface{} = p.After.Value, p.After.Key
+	var err error
+	if strings.HasSuffix(p.Sort, "_at") {
+		if value, err = time.Parse(sortTimeLayout, p.After.Value); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
+	if o.numericKey {
+		if key, err = strconv.ParseUint(p.After.Key, 10, 64); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
+	return value, key, nil
+}
+
+// Sorts the query by the page's field then by the key and starts it after the page's cursor (keyset pagination).
+// One more row than the limit is loaded to know whether there is a next page, see trim.
+func (o listOrder[T]) apply(query *gorm.DB, p *Page) (*gorm.DB, error) {
+	if p == nil {
+		return query.Order(o.fallback), nil
+	}
+	column, ok := o.columns[p.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", p.Sort)
+	}
+	direction, compare := "", ">"
+	if p.Desc {
+		direction, compare = " DESC", "<"
+	}
+
+	if p.After != nil {
+		value, key, err := o.cursorArgs(p)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", column, o.keyColumn, compare), value, key)
+	}
+	return query.Order(column + direction + ", " + o.keyColumn + direction).Limit(p.Limit + 1), nil
+}
+
+// Drops the extra row loaded by apply and returns the cursor of the next page, nil if this is the last page
+func (o listOrder[T]) trim(items []T, p *Page) ([]T, *Cursor) {
+	if p == nil || len(items) <= p.Limit {
+		return items, nil
+	}
+	items = items[:p.Limit]
+	last := items[len(items)-1]
+	return items, &Cursor{Value: o.value(last, p.Sort), Key: o.key(last)}
+}
+
+// The text columns are compared byte-wise like the Go strings, whatever the collation of the database
+var (
+	teacherOrder = listOrder[Teacher]{
+		value: func(teacher Teacher, field string) string {
+			switch field {
+			case "name":
+				return strings.ToLower(teacher.Name)
+			case "created_at":
+				return SortTime(teacher.CreatedAt)
+			default:
+				return teacher.Email
+			}
+		},
+		key:       func(teacher Teacher) string { return teacher.Email },
+		columns:   map[string]string{"email": `email COLLATE "C"`, "name": `lower(name) COLLATE "C"`, "created_at": "created_at"},
+		keyColumn: `email COLLATE "C"`,
+		fallback:  "email",
+	}
+	studentOrder = listOrder[Student]{
+		value: func(student Student, field string) string {
+			switch field {
+			case "name":
+				return strings.ToLower(student.Name)
+			case "created_at":
+				return SortTime(student.CreatedAt)
+			default:
+				return student.Email
+			}
+		},
+		key:       func(student Student) string { return student.Email },
+		columns:   map[string]string{"email": `email COLLATE "C"`, "name": `lower(name) COLLATE "C"`, "created_at": "created_at"},
+		keyColumn: `email COLLATE "C"`,
+		fallback:  "email",
+	}
+	termOrder = listOrder[Term]{
+		value: func(term Term, field string) string {
+			if field == "name" {
+				return term.Name
+			}
+			return SortTime(term.CreatedAt)
+		},
+		key:       func(term Term) string { return term.Name },
+		columns:   map[string]string{"name": `name COLLATE "C"`, "created_at": "created_at"},
+		keyColumn: `name COLLATE "C"`,
+		fallback:  "id",
+	}
+	classOrder = listOrder[Class]{
+		value: func(class Class, field string) string {
+			switch field {
+			case "name":
+				return strings.ToLower(class.Name)
+			case "created_at":
+				return SortTime(class.CreatedAt)
+			default:
+				return strings.ToLower(class.Code)
+			}
+		},
+		key:       func(class Class) string { return strings.ToLower(class.Code) },
+		columns:   map[string]string{"code": `lower(code) COLLATE "C"`, "name": `lower(name) COLLATE "C"`, "created_at": "created_at"},
+		keyColumn: `lower(code) COLLATE "C"`,
+		fallback:  "code",
+	}
+	// The notifications sent right away are sorted by send_at as if they were scheduled when created
+	notificationOrder = listOrder[Notification]{
+		value: func(notification Notification, field string) string {
+			if field == "send_at" && notification.SendAt != nil {
+				return SortTime(*notification.SendAt)
+			}
+			return SortTime(notification.CreatedAt)
+		},
+		key:       func(notification Notification) string { return notification.ID },
+		columns:   map[string]string{"created_at": "created_at", "send_at": "COALESCE(send_at, created_at)"},
+		keyColumn: `id COLLATE "C"`,
+		fallback:  "created_at DESC, id",
+	}
+	deliveryOrder = listOrder[Delivery]{
+		value:      func(delivery Delivery, field string) string { return SortTime(delivery.CreatedAt) },
+		key:        func(delivery Delivery) string { return SortID(delivery.ID) },
+		columns:    map[string]string{"created_at": "created_at"},
+		keyColumn:  "id",
+		numericKey: true,
+		fallback:   "id",
+	}
+	jobOrder = listOrder[Job]{
+		value: func(job Job, field string) string {
+			if field == "run_at" {
+				return SortTime(job.RunAt)
+			}
+			return SortTime(job.CreatedAt)
+		},
+		key:        func(job Job) string { return SortID(job.ID) },
+		columns:    map[string]string{"created_at": "created_at", "run_at": "run_at"},
+		keyColumn:  "id",
+		numericKey: true,
+		fallback:   "id",
+	}
+	apiKeyOrder = listOrder[APIKey]{
+		value:      func(key APIKey, field string) string { return SortTime(key.CreatedAt) },
+		key:        func(key APIKey) string { return SortID(key.ID) },
+		columns:    map[string]string{"created_at": "created_at"},
+		keyColumn:  "id",
+		numericKey: true,
+		fallback:   "id",
+	}
+	sessionOrder = listOrder[Session]{
+		value:     func(session Session, field string) string { return SortTime(session.CreatedAt) },
+		key:       func(session Session) string { return session.ID },
+		columns:   map[string]string{"created_at": "created_at"},
+		keyColumn: `id COLLATE "C"`,
+		fallback:  "created_at, id",
+	}
+	auditOrder = listOrder[AuditEntry]{
+		value:      func(entry AuditEntry, field string) string { return SortTime(entry.CreatedAt) },
+		key:        func(entry AuditEntry) string { return SortID(entry.ID) },
+		columns:    map[string]string{"created_at": "created_at"},
+		keyColumn:  "id",
+		numericKey: true,
+		fallback:   "id",
+	}
+)
+
+// LIKE pattern matching the values starting with prefix (case insensitive when matched against lower(column))
+func likePrefix(prefix string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(prefix))
+	return escaped + "%"
+}
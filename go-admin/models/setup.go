@@ -3,14 +3,14 @@ package models
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
-
-func ConnectDatabase() {
+// Opens the postgres connection, the schema itself is managed by the migrations package
+func ConnectDatabase() *gorm.DB {
 	dsn := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
 		os.Getenv("DB_HOST"),
@@ -19,15 +19,20 @@ func ConnectDatabase() {
 		os.Getenv("DB_NAME"),
 		os.Getenv("DB_PORT"),
 	)
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	database, err := OpenDatabase(dsn)
 
 	if err != nil {
 		panic("Failed to connect to database")
 	}
 
-	database.AutoMigrate(&Teacher{})
-	database.AutoMigrate(&Student{})
-	database.AutoMigrate(&Registry{})
+	return database
+}
 
-	DB = database
+// Opens a postgres connection to the DSN, either key=value pairs or a postgres:// URL
+func OpenDatabase(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		// Postgres keeps microseconds, the timestamps set by gorm match the ones read back
+		NowFunc: func() time.Time { return time.Now().Local().Truncate(time.Microsecond) },
+	})
 }
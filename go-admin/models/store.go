@@ -0,0 +1,237 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned by a Store when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// Returned by a Store when a record with the same unique key already exists
+var ErrDuplicate = errors.New("record already exists")
+
+// Returned by a Store when a record cannot be deleted because a history which is kept refers to it
+var ErrReferenced = errors.New("record is referenced")
+
+// Returned by a Store when the cursor of a page does not fit the list (e.g. a time which does not parse)
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Store is the persistence layer used by the controllers.
+// GormStore is backed by Postgres, MemoryStore keeps everything in memory (used by tests).
+type Store interface {
+	TeacherStore
+	StudentStore
+	TermStore
+	ClassStore
+	RegistryStore
+	SuspensionStore
+	NotificationStore
+	DeliveryStore
+	JobStore
+	APIKeyStore
+	SessionStore
+	PasswordResetStore
+	TwoFactorStore
+	AuditStore
+}
+
+type TeacherStore interface {
+	CreateTeacher(teacher *Teacher) error
+	GetTeacher(email string) (*Teacher, error)
+	// Returns a page of the matching teachers along with the cursor of the next page (see Page), ordered by email
+	// if page is nil
+	ListTeachers(filter TeacherFilter, page *Page) ([]Teacher, *Cursor, error)
+	UpdateTeacherName(email string, name string) (*Teacher, error)
+	UpdateTeacherRole(email string, role string) (*Teacher, error)
+	// Deletes the teacher along with their own class (see TeacherClassCode), returns ErrReferenced if they sent notifications
+	DeleteTeacher(email string) error
+}
+
+type StudentStore interface {
+	CreateStudent(student *Student) error
+	GetStudent(email string) (*Student, error)
+	// Returns a page of the matching students along with the cursor of the next page, ordered by email if page is nil
+	ListStudents(filter StudentFilter, page *Page) ([]Student, *Cursor, error)
+	UpdateStudent(email string, changes StudentChanges) (*Student, error)
+	// Deletes the student along with their enrollments, returns ErrReferenced if they have a suspension history
+	DeleteStudent(email string) error
+	// Returns the emails of the students in the cohort, ordered by email (case insensitive match)
+	GetCohortStudents(cohort string) ([]string, error)
+	// Returns which of the given students exist keyed by email, in a single query.
+	// The students who do not exist are left out.
+	ExistingStudents(studentEmails []string) (map[string]bool, error)
+}
+
+type TermStore interface {
+	// Returns ErrDuplicate if the name is taken, or if the term is current while another term is current
+	CreateTerm(term *Term) error
+	GetTerm(name string) (*Term, error)
+	// Returns ErrNotFound if there is no current term
+	GetCurrentTerm() (*Term, error)
+	// Returns a page of the terms along with the cursor of the next page, oldest first if page is nil
+	ListTerms(page *Page) ([]Term, *Cursor, error)
+	// Archives the term, creates next as the current term and copies into it the enrollments of the given classes
+	RolloverTerm(term *Term, next *Term, carryForward []string) error
+}
+
+// The class codes are matched case insensitively, the enrollments are scoped to a term
+type ClassStore interface {
+	// Creates the class along with its teachers
+	CreateClass(class *Class) error
+	GetClass(code string) (*Class, error)
+	// Returns a page of the matching classes along with the cursor of the next page, ordered by code if page is nil
+	ListClasses(filter ClassFilter, page *Page) ([]Class, *Cursor, error)
+	// Updates the name of the class and replaces its teachers
+	UpdateClass(class *Class) error
+	// Deletes the class along with its enrollments
+	DeleteClass(code string) error
+	// Enrolls the student in the class only if not enrolled yet
+	CreateEnrollment(termID uint, code string, studentEmail string) error
+	// Returns ErrNotFound if the student is not enrolled in the class
+	DeleteEnrollment(termID uint, code string, studentEmail string) error
+	// Returns the emails of the students enrolled in the class ordered by email, empty if the class does not exist
+	GetClassStudents(termID uint, code string) ([]string, error)
+	// Returns the codes of every class of the students enrolled in at least one of the given classes, keyed by student
+	GetStudentsClasses(termID uint, codes []string) (map[string][]string, error)
+	// Returns the codes of the classes the student is enrolled in
+	GetStudentClasses(termID uint, studentEmail string) ([]string, error)
+}
+
+// Teacher-level view over the classes, a student is registered under a teacher during a term
+// when enrolled in any of the teacher's classes in that term
+type RegistryStore interface {
+	// Enrolls the student in the teacher's own class (see TeacherClassCode), created if needed
+	CreateRegistry(termID uint, teacherEmail string, studentEmail string) error
+	// Removes the student from every class of the teacher, returns ErrNotFound if the student is in none
+	DeleteRegistry(termID uint, teacherEmail string, studentEmail string) error
+	// Returns the emails of the students registered under a teacher, in order of enrollment
+	GetRegisteredStudents(termID uint, teacherEmail string) ([]string, error)
+	// Returns the emails of the teachers a student is registered under
+	GetStudentTeachers(termID uint, studentEmail string) ([]string, error)
+	// Returns the emails of every teacher of the students registered under at least one of the given teachers, keyed by student
+	GetStudentsTeachers(termID uint, teacherEmails []string) (map[string][]string, error)
+}
+
+type SuspensionStore interface {
+	// Appends an event to the student's suspension history
+	CreateSuspensionEvent(event *SuspensionEvent) error
+	// Returns the student's suspension history ordered from oldest to newest
+	ListSuspensionEvents(studentEmail string) ([]SuspensionEvent, error)
+	// Returns the suspension applying to the student at the given time, ErrNotFound if there is none
+	GetActiveSuspension(studentEmail string, at time.Time) (*Suspension, error)
+	// Returns the suspensions applying to the students at the given time keyed by email, in a single query.
+	// The students who are not suspended are left out.
+	ActiveSuspensions(studentEmails []string, at time.Time) (map[string]*Suspension, error)
+	// Returns the emails of the students suspended at the given time, ordered by email
+	ListSuspendedStudents(at time.Time) ([]string, error)
+	// Records an expire event for every suspension which ended before the given time and was not lifted,
+	// returns the number of events recorded
+	ExpireSuspensions(at time.Time, actor string) (int, error)
+}
+
+type NotificationStore interface {
+	// Stores the notification along with its recipients and the given jobs (e.g. the job sending it), all at once
+	CreateNotification(notification *Notification, jobs ...*Job) error
+	GetNotification(id string) (*Notification, error)
+	// Updates the status and times of the notification and replaces its recipients
+	UpdateNotification(notification *Notification) error
+	// Returns a page of the matching notifications along with the cursor of the next page, newest first if page is nil
+	ListNotifications(filter NotificationFilter, page *Page) ([]Notification, *Cursor, error)
+}
+
+type DeliveryStore interface {
+	// Creates the deliveries, filling in their IDs, along with the job sending each of them (built by newJob once
+	// the ID of the delivery is known), all at once
+	CreateDeliveries(deliveries []Delivery, newJob func(delivery Delivery) (*Job, error)) error
+	GetDelivery(id uint) (*Delivery, error)
+	// Returns a page of the deliveries of the notification along with the cursor of the next page,
+	// oldest first if page is nil
+	ListNotificationDeliveries(notificationID string, page *Page) ([]Delivery, *Cursor, error)
+	UpdateDelivery(delivery *Delivery) error
+}
+
+type JobStore interface {
+	CreateJob(job *Job) error
+	GetJob(id uint) (*Job, error)
+	// Locks the next queued job whose RunAt has passed, marks it as running and increments its attempts.
+	// Returns ErrNotFound if there is no job to run.
+	ClaimJob(now time.Time) (*Job, error)
+	UpdateJob(job *Job) error
+	// Returns a page of the jobs with the given status (every job if empty) along with the cursor of the next page,
+	// oldest first if page is nil
+	ListJobs(status string, page *Page) ([]Job, *Cursor, error)
+	// Puts back in the queue the running jobs locked before the given time (e.g. their worker died),
+	// returns the number of jobs requeued
+	RequeueStaleJobs(lockedBefore time.Time) (int, error)
+	// Puts a dead job back in the queue to be run at runAt with a fresh set of attempts, only if it is still dead.
+	// Returns ErrNotFound if there is no dead job with this ID.
+	RetryJob(id uint, runAt time.Time) (*Job, error)
+}
+
+type APIKeyStore interface {
+	// Returns ErrNotFound if the teacher does not exist, ErrDuplicate if the hash is taken
+	CreateAPIKey(key *APIKey) error
+	// Returns the key with the given hash (revoked or not), ErrNotFound if there is none
+	GetAPIKeyByHash(hash string) (*APIKey, error)
+	// Returns a page of the teacher's keys along with the cursor of the next page, oldest first if page is nil
+	ListAPIKeys(teacherEmail string, page *Page) ([]APIKey, *Cursor, error)
+	// Returns ErrNotFound if the teacher has no such key or it is already revoked
+	RevokeAPIKey(teacherEmail string, id uint, at time.Time) error
+}
+
+type SessionStore interface {
+	// Returns ErrNotFound if the teacher does not exist
+	CreateSession(session *Session) error
+	// Returns the session whose access token has the given hash (revoked or not), ErrNotFound if there is none
+	GetSessionByAccessHash(hash string) (*Session, error)
+	// Returns the session whose refresh token has the given hash (revoked or not), ErrNotFound if there is none
+	GetSessionByRefreshHash(hash string) (*Session, error)
+	// Returns a page of the teacher's sessions along with the cursor of the next page, oldest first if page is nil
+	ListSessions(teacherEmail string, page *Page) ([]Session, *Cursor, error)
+	// Replaces the tokens of the session if its refresh token still has the previous hash and it is not revoked,
+	// returns ErrNotFound otherwise (e.g. the refresh token was already used)
+	RotateSession(session *Session, previousRefreshHash string) error
+	// Returns ErrNotFound if the teacher has no such session or it is already revoked
+	RevokeSession(teacherEmail string, id string, at time.Time) error
+	// Records that the session passed the two-factor authentication, returns ErrNotFound if it is revoked
+	VerifySessionTwoFactor(id string, at time.Time) error
+}
+
+type PasswordResetStore interface {
+	// Returns ErrNotFound if the teacher does not exist
+	CreatePasswordReset(reset *PasswordReset) error
+	// Marks the reset with the given hash as used, sets the password of its teacher and revokes their sessions,
+	// all at once. Returns ErrNotFound if there is no such reset, or if it is used or expired.
+	ResetPassword(hash string, passwordHash string, at time.Time) (*PasswordReset, error)
+}
+
+type TwoFactorStore interface {
+	// Sets the TOTP secret the teacher is enrolling with, returns ErrNotFound if the teacher does not exist
+	// and ErrDuplicate if their TOTP is already enabled
+	SetTOTPSecret(teacherEmail string, secret string) error
+	// Enables the TOTP of the teacher, along with the step of the code they confirmed it with,
+	// and replaces their recovery codes. Returns ErrNotFound if the teacher does not exist.
+	EnableTOTP(teacherEmail string, step int64, recoveryHashes []string) error
+	// Records the step of a TOTP code the teacher used, returns ErrDuplicate if a code of this step
+	// (or a later one) was already used
+	UseTOTPStep(teacherEmail string, step int64) error
+	// Marks the unused recovery code with the given hash as used, returns ErrNotFound if there is none
+	UseRecoveryCode(teacherEmail string, hash string, at time.Time) error
+	// Returns the number of unused recovery codes of the teacher
+	CountRecoveryCodes(teacherEmail string) (int, error)
+	// Counts a code of the teacher which was rejected, returns the number of codes rejected in a row.
+	// Returns ErrNotFound if the teacher does not exist.
+	RecordTwoFactorFailure(teacherEmail string) (int, error)
+	// Forgets the codes of the teacher rejected so far and locks their two-factor authentication until the given time,
+	// unlocks it if nil. Returns ErrNotFound if the teacher does not exist.
+	ResetTwoFactorFailures(teacherEmail string, lockedUntil *time.Time) error
+}
+
+// The audit log is append-only, its entries are never updated nor deleted
+type AuditStore interface {
+	CreateAuditEntry(entry *AuditEntry) error
+	// Returns a page of the entries matching the filter along with the cursor of the next page,
+	// oldest first if page is nil
+	ListAuditEntries(filter AuditFilter, page *Page) ([]AuditEntry, *Cursor, error)
+}
@@ -0,0 +1,201 @@
+package notifications
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bensohh/go-admin/mentions"
+	"github.com/bensohh/go-admin/models"
+)
+
+// Explains why a student received a notification or not
+type Candidate struct {
+	Email    string   `json:"email"`
+	Included bool     `json:"included"`
+	Reasons  []string `json:"reasons"`
+	// Rule which excluded the candidate, empty if included
+	ExcludedBy string `json:"excluded_by,omitempty"`
+}
+
+func (c *Candidate) hasReason(reason string) bool {
+	for _, r := range c.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Recipients of a notification along with the @mentions which could not be notified
+type Resolution struct {
+	Recipients []string
+	// @mentioned emails which are not students
+	UnknownMentions []string
+	// @mentioned students which are suspended
+	SuspendedMentions []string
+	// @mentioned groups without any student (e.g. class:9Z)
+	UnknownGroups []string
+	// Every candidate, included or not, in the order of the sources which picked them
+	Candidates []Candidate
+	// Index of each candidate in Candidates by email
+	index map[string]int
+}
+
+// Returns the candidate with the given email, adding it if it is not there yet
+func (r *Resolution) candidate(email string) *Candidate {
+	if i, ok := r.index[email]; ok {
+		return &r.Candidates[i]
+	}
+	r.index[email] = len(r.Candidates)
+	r.Candidates = append(r.Candidates, Candidate{Email: email, Reasons: []string{}})
+	return &r.Candidates[len(r.Candidates)-1]
+}
+
+// Notification being resolved, shared by the rules
+type Request struct {
+	TeacherEmail string
+	Text         string
+	// Term whose enrollments are used, 0 if there is no current term
+	TermID uint
+	At     time.Time
+	// Emails @mentioned in the text
+	Mentions []string
+	// Groups @mentioned in the text
+	Groups []mentions.Group
+	// Groups which could not be expanded, filled in by the sources
+	UnknownGroups []string
+	// Emails of every candidate, filled in before the filters run so they can look them up at once
+	Candidates []string
+
+	// Whether each candidate exists, loaded by the unknown student filter
+	existing map[string]bool
+	// Whether each candidate is suspended, loaded by the suspended filter
+	suspended map[string]bool
+}
+
+// Resolves the recipients of a notification: the candidates picked by any of the sources,
+// minus the ones excluded by any of the filters
+type RecipientResolver struct {
+	Store   models.Store
+	Sources []Source
+	Filters []Filter
+}
+
+func NewRecipientResolver(store models.Store, sources []Source, filters []Filter) *RecipientResolver {
+	return &RecipientResolver{Store: store, Sources: sources, Filters: filters}
+}
+
+// Students registered under the teacher OR @mentioned (directly or through a group), minus the unknown and suspended ones
+func DefaultResolver(store models.Store) *RecipientResolver {
+	return NewRecipientResolver(
+		store,
+		[]Source{MentionedSource{}, GroupSource{}, RegisteredSource{}},
+		[]Filter{UnknownStudentFilter{}, SuspendedFilter{}},
+	)
+}
+
+// Resolves the recipients among the enrollments of the given term, the current term is used if termID is 0
+func (r *RecipientResolver) Resolve(teacherEmail string, text string, termID uint, at time.Time) (*Resolution, error) {
+	if termID == 0 {
+		term, err := r.Store.GetCurrentTerm()
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			return nil, err
+		}
+		if term != nil {
+			termID = term.ID
+		}
+	}
+
+	request := &Request{
+		TeacherEmail: teacherEmail,
+		Text:         text,
+		TermID:       termID,
+		At:           at,
+		Mentions:     mentions.Parse(text),
+		Groups:       mentions.ParseGroups(text),
+	}
+	resolution := &Resolution{
+		Recipients:        []string{},
+		UnknownMentions:   []string{},
+		SuspendedMentions: []string{},
+		Candidates:        []Candidate{},
+		index:             make(map[string]int),
+	}
+
+	// Gather the candidates with the reasons they were picked
+	for _, source := range r.Sources {
+		emails, err := source.Candidates(r.Store, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, email := range emails {
+			candidate := resolution.candidate(email)
+			if !candidate.hasReason(source.Reason()) {
+				candidate.Reasons = append(candidate.Reasons, source.Reason())
+			}
+		}
+	}
+
+	resolution.UnknownGroups = append([]string{}, request.UnknownGroups...)
+	for _, candidate := range resolution.Candidates {
+		request.Candidates = append(request.Candidates, candidate.Email)
+	}
+
+	// The group members are filtered like the other candidates, the first filter excluding a candidate is reported
+	for i := range resolution.Candidates {
+		candidate := &resolution.Candidates[i]
+		for _, filter := range r.Filters {
+			excluded, err := filter.Excludes(r.Store, request, candidate.Email)
+			if err != nil {
+				return nil, err
+			}
+			if excluded {
+				candidate.ExcludedBy = filter.Rule()
+				break
+			}
+		}
+
+		if candidate.ExcludedBy == "" {
+			candidate.Included = true
+			resolution.Recipients = append(resolution.Recipients, candidate.Email)
+		}
+
+		// Keep track of the @mentions which failed
+		if candidate.hasReason(ReasonMentioned) {
+			switch candidate.ExcludedBy {
+			case ExcludedUnknownStudent:
+				resolution.UnknownMentions = append(resolution.UnknownMentions, candidate.Email)
+			case ExcludedSuspended:
+				resolution.SuspendedMentions = append(resolution.SuspendedMentions, candidate.Email)
+			}
+		}
+	}
+
+	return resolution, nil
+}
+
+// Retrieve list of students who can receive a given notification at the given time with the default rules
+// (among the enrollments of the current term)
+func ResolveRecipients(store models.Store, teacherEmail string, text string, at time.Time) (*Resolution, error) {
+	return DefaultResolver(store).Resolve(teacherEmail, text, 0, at)
+}
+
+// Checks if a student is suspended at the given time, students which do not exist are treated as suspended
+func IsSuspended(store models.Store, email string, at time.Time) bool {
+	if _, err := store.GetStudent(email); err != nil {
+		return true
+	}
+	return isSuspended(store, email, at)
+}
+
+func isSuspended(store models.Store, email string, at time.Time) bool {
+	_, err := store.GetActiveSuspension(email, at)
+	if errors.Is(err, models.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bensohh/go-admin/mentions"
+	"github.com/bensohh/go-admin/models"
+)
+
+// Reasons a student is a candidate recipient of a notification
+const (
+	ReasonMentioned  = "mentioned"
+	ReasonGroup      = "group"
+	ReasonRegistered = "registered"
+)
+
+// Rules excluding a candidate from the recipients
+const (
+	ExcludedUnknownStudent = "unknown_student"
+	ExcludedSuspended      = "suspended"
+)
+
+// Include rule, picks candidate recipients
+type Source interface {
+	// Reason reported for the candidates picked by the source
+	Reason() string
+	Candidates(store models.Store, request *Request) ([]string, error)
+}
+
+// Exclude rule, removes candidates from the recipients
+type Filter interface {
+	// Rule reported for the candidates excluded by the filter
+	Rule() string
+	Excludes(store models.Store, request *Request, email string) (bool, error)
+}
+
+// Picks the @mentioned students
+type MentionedSource struct{}
+
+func (MentionedSource) Reason() string { return ReasonMentioned }
+
+func (MentionedSource) Candidates(store models.Store, request *Request) ([]string, error) {
+	return request.Mentions, nil
+}
+
+// Expands the @mentioned groups (@class:3A, @cohort:2027, @all-my-students) into their students,
+// the groups without any student are reported as unknown
+type GroupSource struct{}
+
+func (GroupSource) Reason() string { return ReasonGroup }
+
+func (GroupSource) Candidates(store models.Store, request *Request) ([]string, error) {
+	candidates := []string{}
+	for _, group := range request.Groups {
+		var students []string
+		var err error
+		switch group.Kind {
+		case mentions.GroupClass:
+			students, err = store.GetClassStudents(request.TermID, group.Name)
+		case mentions.GroupCohort:
+			students, err = store.GetCohortStudents(group.Name)
+		case mentions.GroupAllMyStudents:
+			students, err = store.GetRegisteredStudents(request.TermID, request.TeacherEmail)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(students) == 0 && group.Kind != mentions.GroupAllMyStudents {
+			request.UnknownGroups = append(request.UnknownGroups, group.String())
+		}
+		candidates = append(candidates, students...)
+	}
+	return candidates, nil
+}
+
+// Picks the students registered under the teacher during the term
+type RegisteredSource struct{}
+
+func (RegisteredSource) Reason() string { return ReasonRegistered }
+
+func (RegisteredSource) Candidates(store models.Store, request *Request) ([]string, error) {
+	return store.GetRegisteredStudents(request.TermID, request.TeacherEmail)
+}
+
+// Excludes the emails which are not students, every candidate is looked up at once
+type UnknownStudentFilter struct{}
+
+func (UnknownStudentFilter) Rule() string { return ExcludedUnknownStudent }
+
+func (UnknownStudentFilter) Excludes(store models.Store, request *Request, email string) (bool, error) {
+	if existing, ok := request.existing[email]; ok {
+		return !existing, nil
+	}
+
+	emails := append([]string{email}, request.Candidates...)
+	students, err := store.ExistingStudents(emails)
+	if err != nil {
+		return false, err
+	}
+	request.existing = make(map[string]bool, len(emails))
+	for _, candidate := range emails {
+		request.existing[candidate] = students[candidate]
+	}
+	return !request.existing[email], nil
+}
+
+// Excludes the students suspended at the time of sending, the suspensions of every candidate are loaded at once
+type SuspendedFilter struct{}
+
+func (SuspendedFilter) Rule() string { return ExcludedSuspended }
+
+func (SuspendedFilter) Excludes(store models.Store, request *Request, email string) (bool, error) {
+	if suspended, ok := request.suspended[email]; ok {
+		return suspended, nil
+	}
+
+	emails := append([]string{email}, request.Candidates...)
+	suspensions, err := store.ActiveSuspensions(emails, request.At)
+	if err != nil {
+		return false, err
+	}
+	request.suspended = make(map[string]bool, len(emails))
+	for _, candidate := range emails {
+		request.suspended[candidate] = suspensions[candidate] != nil
+	}
+	return request.suspended[email], nil
+}
+
+// Rules which can be enabled by name (e.g. through the NOTIFICATION_SOURCES and NOTIFICATION_FILTERS variables),
+// new rules are added here
+var (
+	Sources = map[string]Source{
+		ReasonMentioned:  MentionedSource{},
+		ReasonGroup:      GroupSource{},
+		ReasonRegistered: RegisteredSource{},
+	}
+	Filters = map[string]Filter{
+		ExcludedUnknownStudent: UnknownStudentFilter{},
+		ExcludedSuspended:      SuspendedFilter{},
+	}
+)
+
+// Builds a resolver from comma separated rule names, e.g. "mentioned,group,registered" and "unknown_student,suspended".
+// The unknown student filter is always applied since there is nobody to deliver to.
+func ParseResolver(store models.Store, sources string, filters string) (*RecipientResolver, error) {
+	resolver := NewRecipientResolver(store, nil, []Filter{UnknownStudentFilter{}})
+
+	for _, name := range splitNames(sources) {
+		source, ok := Sources[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown notification source %q", name)
+		}
+		resolver.Sources = append(resolver.Sources, source)
+	}
+	for _, name := range splitNames(filters) {
+		filter, ok := Filters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown notification filter %q", name)
+		}
+		if name != ExcludedUnknownStudent {
+			resolver.Filters = append(resolver.Filters, filter)
+		}
+	}
+	if len(resolver.Sources) == 0 {
+		return nil, errors.New("at least one notification source is needed")
+	}
+	return resolver, nil
+}
+
+func splitNames(names string) []string {
+	result := []string{}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
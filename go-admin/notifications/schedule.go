@@ -0,0 +1,173 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bensohh/go-admin/delivery"
+	"github.com/bensohh/go-admin/jobs"
+	"github.com/bensohh/go-admin/models"
+)
+
+// Kind of the job sending a scheduled notification
+const SendJob = "notification.send"
+
+// Kind of the job queuing the deliveries of a notification sent right away, they are queued along with the request
+// unless it failed to
+const DeliverJob = "notification.deliver"
+
+// Delay before the deliver job runs, by then the request has usually queued the deliveries
+const deliverDelay = time.Minute
+
+// Returned when rescheduling or cancelling a notification which is not scheduled anymore
+var ErrNotScheduled = errors.New("notification is not scheduled")
+
+type sendPayload struct {
+	NotificationID string    `json:"notification_id"`
+	SendAt         time.Time `json:"send_at"`
+}
+
+type deliverPayload struct {
+	NotificationID string `json:"notification_id"`
+}
+
+// Resolves the recipients of a new notification among the enrollments of the term (the current term if 0),
+// stores it and queues its deliveries. The notification is stored along with a deliver job, which queues the
+// deliveries later if queuing them now fails.
+func Send(resolver *RecipientResolver, notification *models.Notification, termID uint, at time.Time) (*Resolution, error) {
+	store := resolver.Store
+	resolution, err := resolver.Resolve(notification.TeacherEmail, notification.Text, termID, at)
+	if err != nil {
+		return nil, err
+	}
+	notification.Recipients = resolution.Recipients
+	notification.Status = models.NotificationSent
+	notification.SentAt = &at
+	job, err := jobs.New(DeliverJob, deliverPayload{NotificationID: notification.ID}, at.Add(deliverDelay))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CreateNotification(notification, job); err != nil {
+		return nil, err
+	}
+	if err := delivery.Enqueue(store, notification); err != nil {
+		log.Printf("Unable to queue the deliveries of notification %s, left to job %d: %v", notification.ID, job.ID, err)
+	}
+	return resolution, nil
+}
+
+// Stores a new notification to be sent at sendAt along with the job sending it, the recipients are resolved
+// when it is sent
+func Schedule(store models.Store, notification *models.Notification, sendAt time.Time) error {
+	sendAt = storedTime(sendAt)
+	notification.Status = models.NotificationScheduled
+	notification.SendAt = &sendAt
+	job, err := jobs.New(SendJob, sendPayload{NotificationID: notification.ID, SendAt: sendAt}, sendAt)
+	if err != nil {
+		return err
+	}
+	return store.CreateNotification(notification, job)
+}
+
+// Moves a scheduled notification to sendAt, the job queued for the previous time is skipped
+func Reschedule(store models.Store, notification *models.Notification, sendAt time.Time) error {
+	if notification.Status != models.NotificationScheduled {
+		return ErrNotScheduled
+	}
+	sendAt = storedTime(sendAt)
+	// Queued first, the job is skipped if the notification cannot be updated (see Handle)
+	if _, err := jobs.Enqueue(store, SendJob, sendPayload{NotificationID: notification.ID, SendAt: sendAt}, sendAt); err != nil {
+		return err
+	}
+	notification.SendAt = &sendAt
+	return store.UpdateNotification(notification)
+}
+
+// Rounds t to the precision of the database (microseconds), so the send time of a job matches the stored one
+func storedTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// Cancels a scheduled notification, its job is skipped
+func Cancel(store models.Store, notification *models.Notification) error {
+	if notification.Status != models.NotificationScheduled {
+		return ErrNotScheduled
+	}
+	notification.Status = models.NotificationCancelled
+	return store.UpdateNotification(notification)
+}
+
+// Sends the scheduled notifications when their time comes
+type Scheduler struct {
+	Store    models.Store
+	Resolver *RecipientResolver
+}
+
+func NewScheduler(resolver *RecipientResolver) *Scheduler {
+	return &Scheduler{Store: resolver.Store, Resolver: resolver}
+}
+
+// Registers the scheduler as the handler of the send and deliver jobs
+func (s *Scheduler) Register(queue *jobs.Queue) {
+	queue.Register(SendJob, s.Handle)
+	queue.Register(DeliverJob, s.HandleDeliver)
+}
+
+// Handles a send job, the recipients are resolved at send time (in the then current term)
+// so the suspensions and enrollments made in between apply
+func (s *Scheduler) Handle(ctx context.Context, job *models.Job) error {
+	var payload sendPayload
+	if err := jobs.DecodePayload(job, &payload); err != nil {
+		return err
+	}
+
+	notification, err := s.Store.GetNotification(payload.NotificationID)
+	if errors.Is(err, models.ErrNotFound) {
+		// Deleted along with its teacher
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("notification %s: %w", payload.NotificationID, err)
+	}
+
+	// Cancelled, already sent or rescheduled to another time
+	if notification.Status != models.NotificationScheduled || notification.SendAt == nil || !notification.SendAt.Equal(payload.SendAt) {
+		return nil
+	}
+
+	now := time.Now()
+	resolution, err := s.Resolver.Resolve(notification.TeacherEmail, notification.Text, 0, now)
+	if err != nil {
+		return err
+	}
+	notification.Recipients = resolution.Recipients
+	// Queued before the notification is marked as sent, if either fails the job is retried and only queues the
+	// deliveries still missing
+	if err := delivery.Enqueue(s.Store, notification); err != nil {
+		return err
+	}
+	notification.Status = models.NotificationSent
+	notification.SentAt = &now
+	return s.Store.UpdateNotification(notification)
+}
+
+// Handles a deliver job, queues the deliveries of the notification which are still missing
+func (s *Scheduler) HandleDeliver(ctx context.Context, job *models.Job) error {
+	var payload deliverPayload
+	if err := jobs.DecodePayload(job, &payload); err != nil {
+		return err
+	}
+
+	notification, err := s.Store.GetNotification(payload.NotificationID)
+	if errors.Is(err, models.ErrNotFound) {
+		// Deleted along with its teacher
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("notification %s: %w", payload.NotificationID, err)
+	}
+	return delivery.Enqueue(s.Store, notification)
+}
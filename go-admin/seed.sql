@@ -0,0 +1,15 @@
+-- Sample data used by the Postman test, run after `go run main.go migrate up`
+INSERT INTO teachers (name, email, role) VALUES ('Ken', 'teacherken@gmail.com', 'admin');
+INSERT INTO teachers (name, email) VALUES ('Joe', 'teacherjoe@gmail.com');
+
+INSERT INTO students (name, email) VALUES ('Jon', 'studentjon@gmail.com');
+INSERT INTO students (name, email) VALUES ('Hon', 'studenthon@gmail.com');
+INSERT INTO students (name, email) VALUES ('Tom', 'studenttom@gmail.com');
+INSERT INTO students (name, email) VALUES ('Tom', 'studentunderkenonly@gmail.com');
+
+-- Joe's own class backs the teacher-level registrations (its code is the teacher's email),
+-- the students are enrolled during the term created by the 0011_create_terms migration
+INSERT INTO classes (code, name) VALUES ('teacherjoe@gmail.com', 'teacherjoe@gmail.com');
+INSERT INTO class_teachers (class_id, teacher_email) SELECT id, 'teacherjoe@gmail.com' FROM classes WHERE code = 'teacherjoe@gmail.com';
+INSERT INTO enrollments (term_id, class_id, student_email) SELECT (SELECT id FROM terms WHERE is_current), id, 'studentjon@gmail.com' FROM classes WHERE code = 'teacherjoe@gmail.com';
+INSERT INTO enrollments (term_id, class_id, student_email) SELECT (SELECT id FROM terms WHERE is_current), id, 'studenthon@gmail.com' FROM classes WHERE code = 'teacherjoe@gmail.com';
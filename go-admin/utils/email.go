@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+// Trims and lowercases an email, returns ErrInvalidEmail if it is not a plain address (e.g. "Ken <ken@gmail.com>")
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
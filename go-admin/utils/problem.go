@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes shared by every package, the controllers define the codes of their own errors
+const (
+	CodeMalformedRequest = "MALFORMED_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodeInternalError    = "INTERNAL_ERROR"
+)
+
+// Error response following RFC 7807 (application/problem+json), along with a stable code the clients can match on
+type Problem struct {
+	// The codes tell the errors apart, so the type is always about:blank and the title is the HTTP status text
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// e.g. TEACHER_NOT_FOUND, stable unlike the detail
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+	// ID of the request, see RequestIDMiddleware
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// Invalid field of a request, returned with the VALIDATION_FAILED code
+type FieldError struct {
+	// JSON name of the field (or query param), e.g. "students[2]" for an element of an array
+	Field string `json:"field"`
+	// Rule the value breaks, e.g. "required" or "email"
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// Responds with a problem. The instance is the request ID the RequestIDMiddleware set on the response.
+func RespondWithError(w http.ResponseWriter, status int, code string, detail string) {
+	respondWithProblem(w, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// Responds with a 400 VALIDATION_FAILED problem listing the invalid fields
+func RespondWithValidationError(w http.ResponseWriter, detail string, errors ...FieldError) {
+	respondWithProblem(w, Problem{Status: http.StatusBadRequest, Code: CodeValidationFailed, Detail: detail, Errors: errors})
+}
+
+func respondWithProblem(w http.ResponseWriter, problem Problem) {
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+	problem.Instance = w.Header().Get(RequestIDHeader)
+	response, _ := json.Marshal(problem)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	w.Write(response)
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+)
+
+// Header carrying the ID of a request, echoed in the response
+const RequestIDHeader = "X-Request-ID"
+
+// IDs sent by the clients are kept if they look like this, a new one is generated otherwise
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// Adds the ID of the request (the X-Request-ID header if valid, a new ID otherwise) to its context and its response
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = NewID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// Returns the ID added by RequestIDMiddleware, empty if the request did not go through it
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
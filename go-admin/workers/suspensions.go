@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/bensohh/go-admin/models"
+)
+
+// Actor recorded in the expire events
+const SystemActor = "system"
+
+// Periodically records an expire event for the suspensions whose end time has passed
+type SuspensionExpiryWorker struct {
+	Store    models.SuspensionStore
+	Interval time.Duration
+}
+
+func NewSuspensionExpiryWorker(store models.SuspensionStore, interval time.Duration) *SuspensionExpiryWorker {
+	return &SuspensionExpiryWorker{Store: store, Interval: interval}
+}
+
+// Runs until the context is cancelled
+func (w *SuspensionExpiryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.RunOnce(time.Now()); err != nil {
+			log.Println("Error expiring suspensions:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Expires the suspensions which ended before `now`, returns the number of suspensions expired
+func (w *SuspensionExpiryWorker) RunOnce(now time.Time) (int, error) {
+	count, err := w.Store.ExpireSuspensions(now, SystemActor)
+	if count > 0 {
+		log.Printf("Expired %d suspension(s)", count)
+	}
+	return count, err
+}